@@ -24,8 +24,9 @@ func Connect() error {
 		dialect = postgres.Open(dbURL)
 	}
 
-	// Open database connection
-	Database, err = gorm.Open(dialect, &gorm.Config{})
+	// Open database connection. TranslateError turns driver errors such as unique violations into gorm's
+	// sentinel errors so handlers can match them with errors.Is
+	Database, err = gorm.Open(dialect, &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic("failed to connect database")
 	}
@@ -35,6 +36,25 @@ func Connect() error {
 		&models.MindMapConnection{},
 		&models.MindMapNodeLayout{},
 		&models.BlocksScore{},
+		&models.MindMapPathExplanation{},
+		&models.MindMapQuizResult{},
+		&models.MindMapQuiz{},
+		&models.MindMapOperation{},
+		&models.MindMapNode{},
+		&models.MindMapSnapshot{},
+		&models.RelationshipType{},
+		&models.Concept{},
+		&models.Section{},
+		&models.FlashcardMedia{},
+		&models.ReviewState{},
+		&models.ShareLink{},
+		&models.Classroom{},
+		&models.ClassroomMember{},
+		&models.ClassroomAssignment{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.PersonalAccessToken{},
+		&models.DataExport{},
 	)
 	if err != nil {
 		panic("failed to auto migrate database")
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/andrewpaige1/nodebook-api/storage"
+)
+
+// NewStorage builds the blob store selected by STORAGE_BACKEND: "local"
+// (the default) keeps files under STORAGE_DIR, "s3" uses an S3-compatible bucket.
+func NewStorage() (storage.Store, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return storage.NewLocalStore(dir)
+	case "s3":
+		store := &storage.S3Store{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+		}
+		if store.Endpoint == "" || store.Bucket == "" || store.Region == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT, S3_REGION and S3_BUCKET are required for the s3 storage backend")
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// MediaURLSecret returns the key used to sign media download URLs. Without
+// MEDIA_URL_SECRET a random key is used, so links stop working on restart.
+func MediaURLSecret() []byte {
+	if secret := os.Getenv("MEDIA_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Printf("Warning: MEDIA_URL_SECRET is not set, media links will expire on restart")
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
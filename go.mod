@@ -14,6 +14,7 @@ require github.com/golang-jwt/jwt/v5 v5.2.1
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/matoous/go-nanoid/v2 v2.1.0
+	gopkg.in/go-jose/go-jose.v2 v2.6.3
 	gorm.io/driver/postgres v1.5.11
 )
 
@@ -32,7 +33,6 @@ require (
 	github.com/segmentio/asm v1.2.0 // indirect
 	golang.org/x/oauth2 v0.31.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
-	gopkg.in/go-jose/go-jose.v2 v2.6.3 // indirect
 )
 
 require (
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+type accessTokenResponse struct {
+	models.PersonalAccessToken
+	Scopes []string
+	Live   bool
+	Token  string `json:",omitempty"` // Only returned when the token is created
+}
+
+func newAccessTokenResponse(pat models.PersonalAccessToken) accessTokenResponse {
+	return accessTokenResponse{
+		PersonalAccessToken: pat,
+		Scopes:              strings.Fields(pat.Scopes),
+		Live:                pat.Live(time.Now()),
+	}
+}
+
+// GET /api/tokens
+func (db *DBHandler) GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var tokens []models.PersonalAccessToken
+	if err := db.Where("user_id = ?", user.ID).Order("id desc").Find(&tokens).Error; err != nil {
+		http.Error(w, "Failed to fetch access tokens", http.StatusInternalServerError)
+		return
+	}
+	response := make([]accessTokenResponse, 0, len(tokens))
+	for _, pat := range tokens {
+		response = append(response, newAccessTokenResponse(pat))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /api/tokens
+func (db *DBHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	// Otherwise a leaked token could mint itself broader, longer-lived ones
+	if utils.ViaAccessToken(r) {
+		http.Error(w, "Access tokens can only be created while signed in", http.StatusForbidden)
+		return
+	}
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name      string     `json:"Name"`
+		Scopes    []string   `json:"Scopes"`
+		ExpiresAt *time.Time `json:"ExpiresAt,omitempty"` // Never expires when omitted
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.TokenScopes, scope) {
+			http.Error(w, "Unknown scope "+scope+"; valid scopes are "+strings.Join(models.TokenScopes, ", "), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "ExpiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	token := utils.AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	slices.Sort(req.Scopes)
+	pat := models.PersonalAccessToken{
+		PublicID:  publicID,
+		UserID:    user.ID,
+		Name:      req.Name,
+		TokenHash: utils.HashAccessToken(token),
+		Prefix:    token[:len(utils.AccessTokenPrefix)+4],
+		Scopes:    strings.Join(slices.Compact(req.Scopes), " "),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := db.Omit("User").Create(&pat).Error; err != nil {
+		http.Error(w, "Failed to create access token", http.StatusInternalServerError)
+		return
+	}
+	response := newAccessTokenResponse(pat)
+	response.Token = token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DELETE /api/tokens/{tokenID}
+//
+// Revoked tokens are kept so they still show when they were last used.
+func (db *DBHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var pat models.PersonalAccessToken
+	if err := db.Where("public_id = ? AND user_id = ?", r.PathValue("tokenID"), user.ID).First(&pat).Error; err != nil {
+		http.Error(w, "Access token not found", http.StatusNotFound)
+		return
+	}
+	if pat.RevokedAt == nil {
+		if err := db.Model(&pat).Update("revoked_at", time.Now()).Error; err != nil {
+			http.Error(w, "Failed to revoke access token", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+// accountDeletionGracePeriod is how long a user has to change their mind
+// after asking for their account to be deleted
+const accountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// POST /api/me/deletion
+//
+// Schedules the caller's account for deletion once the grace period ends.
+// Until then everything keeps working and the request can be cancelled.
+func (db *DBHandler) ScheduleAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		scheduled := time.Now().Add(accountDeletionGracePeriod)
+		if err := db.Model(user).Update("deletion_scheduled_at", scheduled).Error; err != nil {
+			http.Error(w, "Failed to schedule deletion", http.StatusInternalServerError)
+			return
+		}
+		user.DeletionScheduledAt = &scheduled
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newProfileResponse(user))
+}
+
+// DELETE /api/me/deletion
+func (db *DBHandler) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if err := db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		http.Error(w, "Failed to cancel deletion", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunAccountCleanup deletes accounts whose grace period has ended, fails
+// exports that stopped being built and removes expired export files and
+// quizzes, every interval until the process exits.
+func (db *DBHandler) RunAccountCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		db.deleteDueAccounts()
+		db.deleteExpiredExports()
+		db.deleteExpiredQuizzes()
+		<-ticker.C
+	}
+}
+
+func (db *DBHandler) deleteDueAccounts() {
+	var users []models.User
+	if err := db.Where("deletion_scheduled_at <= ?", time.Now()).Find(&users).Error; err != nil {
+		log.Printf("deleteDueAccounts: Failed to fetch accounts: %v", err)
+		return
+	}
+	for i := range users {
+		if err := db.deleteAccount(context.Background(), &users[i]); err != nil {
+			log.Printf("deleteDueAccounts: Failed to delete userID=%d: %v", users[i].ID, err)
+			continue
+		}
+		log.Printf("deleteDueAccounts: Deleted userID=%d", users[i].ID)
+	}
+}
+
+func (db *DBHandler) deleteExpiredExports() {
+	// Exports still pending after the timeout were being built when the
+	// process stopped and will never finish
+	if err := db.Model(&models.DataExport{}).Where("status = ? AND created_at <= ?", models.ExportPending, time.Now().Add(-exportTimeout)).
+		Update("status", models.ExportFailed).Error; err != nil {
+		log.Printf("deleteExpiredExports: Failed to fail stale exports: %v", err)
+	}
+
+	var exports []models.DataExport
+	if err := db.Where("expires_at <= ?", time.Now()).Find(&exports).Error; err != nil {
+		log.Printf("deleteExpiredExports: Failed to fetch exports: %v", err)
+		return
+	}
+	for _, export := range exports {
+		if err := db.Storage.Delete(context.Background(), export.StorageKey); err != nil {
+			log.Printf("deleteExpiredExports: Failed to delete %s: %v", export.StorageKey, err)
+			continue
+		}
+		db.Unscoped().Delete(&export)
+	}
+}
+
+func (db *DBHandler) deleteExpiredQuizzes() {
+	if err := db.Where("created_at <= ?", time.Now().Add(-quizLifetime)).Delete(&models.MindMapQuiz{}).Error; err != nil {
+		log.Printf("deleteExpiredQuizzes: Failed to delete quizzes: %v", err)
+	}
+}
+
+// deleteAccount hard-deletes a user and every row tied to them. Their
+// personal sets go with them. Sets and mind maps they made in an organization
+// stay with it under its most senior remaining member, who becomes an owner
+// if the organization would otherwise have none; an organization with no
+// other members is deleted with everything in it. Classrooms pass to another teacher the same way.
+// Edits they made to other people's mind maps are kept but anonymised.
+func (db *DBHandler) deleteAccount(ctx context.Context, user *models.User) error {
+	var media []models.FlashcardMedia
+	var exports []models.DataExport
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Organizations the user belongs to or still has sets or maps in
+		var memberships []models.OrganizationMember
+		if err := tx.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+			return err
+		}
+		roles := make(map[uint]string)
+		var orgIDs []uint
+		for _, membership := range memberships {
+			roles[membership.OrganizationID] = membership.Role
+			orgIDs = append(orgIDs, membership.OrganizationID)
+		}
+		for _, model := range []any{&models.FlashcardSet{}, &models.MindMap{}} {
+			var ids []uint
+			if err := tx.Unscoped().Model(model).Where("user_id = ? AND organization_id IS NOT NULL", user.ID).
+				Distinct().Pluck("organization_id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if !slices.Contains(orgIDs, id) {
+					orgIDs = append(orgIDs, id)
+				}
+			}
+		}
+
+		dissolved := []uint{}
+		for _, orgID := range orgIDs {
+			var others []models.OrganizationMember
+			if err := tx.Where("organization_id = ? AND user_id != ?", orgID, user.ID).Order("id asc").Find(&others).Error; err != nil {
+				return err
+			}
+			if len(others) == 0 {
+				dissolved = append(dissolved, orgID)
+				continue
+			}
+			heir := others[0]
+			for _, other := range others {
+				if models.OrgRoleRank(other.Role) > models.OrgRoleRank(heir.Role) {
+					heir = other
+				}
+			}
+			if roles[orgID] == models.OrgOwner && heir.Role != models.OrgOwner {
+				if err := tx.Model(&heir).Update("role", models.OrgOwner).Error; err != nil {
+					return err
+				}
+			}
+			for _, model := range []any{&models.FlashcardSet{}, &models.MindMap{}} {
+				if err := tx.Unscoped().Model(model).Where("user_id = ? AND organization_id = ?", user.ID, orgID).
+					Update("user_id", heir.UserID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// A dissolved organization takes all of its content with it, including
+		// sets former members made, since no role check could reach them again
+		setIDs := tx.Unscoped().Model(&models.FlashcardSet{}).Select("id").
+			Where("(user_id = ? AND organization_id IS NULL) OR organization_id IN ?", user.ID, dissolved)
+		cardIDs := tx.Unscoped().Model(&models.Flashcard{}).Select("id").Where("set_id IN (?)", setIDs)
+		if err := tx.Unscoped().Where("flashcard_id IN (?)", cardIDs).Find(&media).Error; err != nil {
+			return err
+		}
+		if err := purgeSets(tx, setIDs); err != nil {
+			return err
+		}
+		if len(dissolved) > 0 {
+			if err := tx.Where("organization_id IN ?", dissolved).Delete(&models.OrganizationMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", dissolved).Delete(&models.Organization{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var classrooms []models.Classroom
+		if err := tx.Where("owner_id = ?", user.ID).Find(&classrooms).Error; err != nil {
+			return err
+		}
+		for _, classroom := range classrooms {
+			var teacher models.ClassroomMember
+			err := tx.Where("classroom_id = ? AND user_id != ? AND role = ?", classroom.ID, user.ID, models.ClassroomTeacher).
+				Order("id asc").First(&teacher).Error
+			if err == nil {
+				if err := tx.Model(&classroom).Update("owner_id", teacher.UserID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			for _, model := range []any{&models.ClassroomAssignment{}, &models.ClassroomMember{}} {
+				if err := tx.Unscoped().Where("classroom_id = ?", classroom.ID).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Delete(&classroom).Error; err != nil {
+				return err
+			}
+		}
+
+		// Operations and snapshots on maps that outlive the user keep the
+		// history intact but no longer point at them
+		for _, model := range []any{&models.MindMapOperation{}, &models.MindMapSnapshot{}} {
+			if err := tx.Unscoped().Model(model).Where("user_id = ?", user.ID).Update("user_id", 0).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Find(&exports).Error; err != nil {
+			return err
+		}
+		for _, model := range []any{
+			&models.ReviewState{},
+			&models.MindMapQuizResult{},
+			&models.MindMapQuiz{},
+			&models.MindMapPathExplanation{},
+			&models.BlocksScore{},
+			&models.PersonalAccessToken{},
+			&models.OrganizationMember{},
+			&models.ClassroomMember{},
+			&models.DataExport{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	db.deleteMediaBlobs(ctx, media)
+	for _, export := range exports {
+		if export.StorageKey == "" {
+			continue
+		}
+		if err := db.Storage.Delete(ctx, export.StorageKey); err != nil {
+			log.Printf("deleteAccount: Failed to delete %s: %v", export.StorageKey, err)
+		}
+	}
+	return nil
+}
+
+// purgeSets hard-deletes the sets selected by setIDs along with their cards,
+// mind maps and everything hanging off them, whether or not the database
+// cascades.
+func purgeSets(tx *gorm.DB, setIDs *gorm.DB) error {
+	mapIDs := tx.Unscoped().Model(&models.MindMap{}).Select("id").Where("set_id IN (?)", setIDs)
+	cardIDs := tx.Unscoped().Model(&models.Flashcard{}).Select("id").Where("set_id IN (?)", setIDs)
+	steps := []struct {
+		model  any
+		column string
+		ids    *gorm.DB
+	}{
+		{&models.MindMapConnection{}, "mind_map_id", mapIDs},
+		{&models.MindMapNodeLayout{}, "mind_map_id", mapIDs},
+		{&models.MindMapNode{}, "mind_map_id", mapIDs},
+		{&models.MindMapOperation{}, "mind_map_id", mapIDs},
+		{&models.MindMapSnapshot{}, "mind_map_id", mapIDs},
+		{&models.MindMapQuizResult{}, "mind_map_id", mapIDs},
+		{&models.MindMapQuiz{}, "mind_map_id", mapIDs},
+		{&models.MindMapPathExplanation{}, "mind_map_id", mapIDs},
+		{&models.ShareLink{}, "mind_map_id", mapIDs},
+		{&models.MindMap{}, "set_id", setIDs},
+		{&models.FlashcardMedia{}, "flashcard_id", cardIDs},
+		{&models.ReviewState{}, "flashcard_id", cardIDs},
+		{&models.Flashcard{}, "set_id", setIDs},
+		{&models.Section{}, "set_id", setIDs},
+		{&models.Concept{}, "set_id", setIDs},
+		{&models.RelationshipType{}, "set_id", setIDs},
+		{&models.ShareLink{}, "set_id", setIDs},
+		{&models.ClassroomAssignment{}, "set_id", setIDs},
+		{&models.BlocksScore{}, "flashcard_set_id", setIDs},
+		{&models.MindMapQuizResult{}, "flashcard_set_id", setIDs},
+		{&models.FlashcardSet{}, "id", setIDs},
+	}
+	for _, step := range steps {
+		if err := tx.Unscoped().Where(step.column+" IN (?)", step.ids).Delete(step.model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,7 +10,7 @@ import (
 
 func (db *DBHandler) GetBlocksLeaderboard(w http.ResponseWriter, r *http.Request) {
 
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
@@ -30,7 +30,7 @@ func (db *DBHandler) GetBlocksLeaderboard(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if !set.IsPublic && set.User.Auth0ID != auth0ID {
+	if !set.IsPublic && !db.hasSetRole(r, &set, models.OrgViewer) && !db.sharedWith(r, set.ID, 0, models.ShareScopeStudy) {
 		http.Error(w, "Set is not public", http.StatusForbidden)
 		return
 	}
@@ -67,7 +67,7 @@ func (db *DBHandler) CreateBlockScore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !set.IsPublic && set.User.Auth0ID != auth0ID {
+	if !set.IsPublic && !db.hasSetRole(r, &set, models.OrgViewer) && !db.sharedWith(r, set.ID, 0, models.ShareScopeStudy) {
 		http.Error(w, "Set is not public", http.StatusForbidden)
 		return
 	}
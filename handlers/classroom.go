@@ -0,0 +1,673 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+// Join codes skip characters that are easy to misread when copied off a board
+const (
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	joinCodeLength   = 8
+)
+
+// assignedToCaller reports whether the set is assigned to a classroom the
+// caller is a member of, as a student or a teacher. An assignment only counts
+// while the set is public or still owned by a teacher of that classroom, so a
+// set that changed hands or went private stops being handed out.
+func (db *DBHandler) assignedToCaller(r *http.Request, setID uint) bool {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		return false
+	}
+	var count int64
+	err := db.Model(&models.ClassroomAssignment{}).
+		Joins("JOIN flashcard_sets ON flashcard_sets.id = classroom_assignments.set_id AND flashcard_sets.deleted_at IS NULL").
+		Joins("JOIN classroom_members ON classroom_members.classroom_id = classroom_assignments.classroom_id").
+		Joins("JOIN users ON users.id = classroom_members.user_id").
+		Where("classroom_assignments.set_id = ? AND users.auth0_id = ?", setID, auth0ID).
+		Where("(flashcard_sets.is_public OR EXISTS (SELECT 1 FROM classroom_members teachers WHERE teachers.classroom_id = classroom_assignments.classroom_id AND teachers.user_id = flashcard_sets.user_id AND teachers.role = ? AND teachers.deleted_at IS NULL))", models.ClassroomTeacher).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+// loadClassroom resolves the classroom in the path for one of its members.
+// Non-members get a 404 so classrooms can't be discovered by ID.
+func (db *DBHandler) loadClassroom(w http.ResponseWriter, r *http.Request) (*models.Classroom, *models.ClassroomMember, bool) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	var classroom models.Classroom
+	if err := db.Where("public_id = ?", r.PathValue("classroomID")).First(&classroom).Error; err != nil {
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	var member models.ClassroomMember
+	if err := db.Where("classroom_id = ? AND user_id = ?", classroom.ID, user.ID).First(&member).Error; err != nil {
+		http.Error(w, "Classroom not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return &classroom, &member, true
+}
+
+// loadClassroomAsTeacher is loadClassroom for teachers only.
+func (db *DBHandler) loadClassroomAsTeacher(w http.ResponseWriter, r *http.Request) (*models.Classroom, *models.ClassroomMember, bool) {
+	classroom, member, ok := db.loadClassroom(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	if member.Role != models.ClassroomTeacher {
+		http.Error(w, "Only teachers can do that", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return classroom, member, true
+}
+
+type classroomResponse struct {
+	models.Classroom
+	Role     string
+	IsOwner  bool
+	JoinCode string `json:",omitempty"` // Teachers only
+}
+
+func newClassroomResponse(classroom models.Classroom, member models.ClassroomMember) classroomResponse {
+	response := classroomResponse{
+		Classroom: classroom,
+		Role:      member.Role,
+		IsOwner:   classroom.OwnerID == member.UserID,
+	}
+	if member.Role == models.ClassroomTeacher {
+		response.JoinCode = classroom.JoinCode
+	}
+	return response
+}
+
+type assignmentResponse struct {
+	models.ClassroomAssignment
+	Set      string // Public ID
+	SetTitle string
+}
+
+func classroomAssignments(db *gorm.DB, classroomID uint) ([]assignmentResponse, error) {
+	var assignments []models.ClassroomAssignment
+	if err := db.Preload("FlashcardSet").Where("classroom_id = ?", classroomID).
+		Order("due_at ASC NULLS LAST").Order("id ASC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	response := make([]assignmentResponse, 0, len(assignments))
+	for _, a := range assignments {
+		response = append(response, assignmentResponse{
+			ClassroomAssignment: a,
+			Set:                 a.FlashcardSet.PublicID,
+			SetTitle:            a.FlashcardSet.Title,
+		})
+	}
+	return response, nil
+}
+
+// GET /api/classrooms
+func (db *DBHandler) GetClassrooms(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var members []models.ClassroomMember
+	if err := db.Preload("Classroom").Where("user_id = ?", user.ID).Order("id asc").Find(&members).Error; err != nil {
+		http.Error(w, "Failed to fetch classrooms", http.StatusInternalServerError)
+		return
+	}
+	response := make([]classroomResponse, 0, len(members))
+	for _, m := range members {
+		response = append(response, newClassroomResponse(m.Classroom, m))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /api/classrooms
+func (db *DBHandler) CreateClassroom(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        string `json:"Name"`
+		Description string `json:"Description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(req.Description) > 1000 {
+		http.Error(w, "Description must be at most 1000 characters", http.StatusBadRequest)
+		return
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	joinCode, err := gonanoid.Generate(joinCodeAlphabet, joinCodeLength)
+	if err != nil {
+		http.Error(w, "Failed to generate join code", http.StatusInternalServerError)
+		return
+	}
+
+	classroom := models.Classroom{
+		PublicID:    publicID,
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     user.ID,
+		JoinCode:    joinCode,
+	}
+	member := models.ClassroomMember{UserID: user.ID, Role: models.ClassroomTeacher}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Owner").Create(&classroom).Error; err != nil {
+			return err
+		}
+		member.ClassroomID = classroom.ID
+		return tx.Omit("Classroom", "User").Create(&member).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to create classroom", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newClassroomResponse(classroom, member))
+}
+
+// GET /api/classrooms/{classroomID}
+func (db *DBHandler) GetClassroom(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+	var members []models.ClassroomMember
+	if err := db.Preload("User").Where("classroom_id = ?", classroom.ID).Order("id asc").Find(&members).Error; err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	assignments, err := classroomAssignments(db.DB, classroom.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch assignments", http.StatusInternalServerError)
+		return
+	}
+
+	type ClassroomDetail struct {
+		classroomResponse
+		Teachers    []string
+		Students    []string `json:",omitempty"` // Teachers only
+		Assignments []assignmentResponse
+	}
+	detail := ClassroomDetail{
+		classroomResponse: newClassroomResponse(*classroom, *member),
+		Teachers:          []string{},
+		Assignments:       assignments,
+	}
+	for _, m := range members {
+		switch {
+		case m.Role == models.ClassroomTeacher:
+			detail.Teachers = append(detail.Teachers, m.User.Nickname)
+		case member.Role == models.ClassroomTeacher:
+			detail.Students = append(detail.Students, m.User.Nickname)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// PUT /api/classrooms/{classroomID}
+func (db *DBHandler) UpdateClassroom(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroomAsTeacher(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        *string `json:"Name,omitempty"`
+		Description *string `json:"Description,omitempty"`
+		NewJoinCode bool    `json:"NewJoinCode"` // Invalidate the old code, e.g. after it leaked
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil {
+		classroom.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		classroom.Description = *req.Description
+	}
+	if classroom.Name == "" || len(classroom.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(classroom.Description) > 1000 {
+		http.Error(w, "Description must be at most 1000 characters", http.StatusBadRequest)
+		return
+	}
+	if req.NewJoinCode {
+		joinCode, err := gonanoid.Generate(joinCodeAlphabet, joinCodeLength)
+		if err != nil {
+			http.Error(w, "Failed to generate join code", http.StatusInternalServerError)
+			return
+		}
+		classroom.JoinCode = joinCode
+	}
+	if err := db.Omit("Owner").Save(classroom).Error; err != nil {
+		http.Error(w, "Failed to update classroom", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newClassroomResponse(*classroom, *member))
+}
+
+// DELETE /api/classrooms/{classroomID}
+func (db *DBHandler) DeleteClassroom(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+	if classroom.OwnerID != member.UserID {
+		http.Error(w, "Only the owner can delete a classroom", http.StatusForbidden)
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("classroom_id = ?", classroom.ID).Delete(&models.ClassroomAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("classroom_id = ?", classroom.ID).Delete(&models.ClassroomMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(classroom).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete classroom", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/classrooms/join
+func (db *DBHandler) JoinClassroom(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Code string `json:"Code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var classroom models.Classroom
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if code == "" || db.Where("join_code = ?", code).First(&classroom).Error != nil {
+		http.Error(w, "No classroom has that join code", http.StatusNotFound)
+		return
+	}
+	var member models.ClassroomMember
+	err := db.Where("classroom_id = ? AND user_id = ?", classroom.ID, user.ID).
+		Attrs(models.ClassroomMember{ClassroomID: classroom.ID, UserID: user.ID, Role: models.ClassroomStudent}).
+		FirstOrCreate(&member).Error
+	if err != nil {
+		http.Error(w, "Failed to join classroom", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newClassroomResponse(classroom, member))
+}
+
+// POST /api/classrooms/{classroomID}/teachers
+//
+// Adds a co-teacher by nickname, promoting them if they're already a student.
+func (db *DBHandler) AddClassroomTeacher(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+	if classroom.OwnerID != member.UserID {
+		http.Error(w, "Only the owner can add teachers", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Nickname string `json:"Nickname"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var teacher models.User
+	if err := db.Where("nickname = ?", req.Nickname).First(&teacher).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	var added models.ClassroomMember
+	err := db.Where("classroom_id = ? AND user_id = ?", classroom.ID, teacher.ID).
+		Assign(models.ClassroomMember{ClassroomID: classroom.ID, UserID: teacher.ID, Role: models.ClassroomTeacher}).
+		FirstOrCreate(&added).Error
+	if err != nil {
+		http.Error(w, "Failed to add teacher", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/classrooms/{classroomID}/members/{nickname}
+//
+// Teachers can remove students and the owner can remove teachers. Anyone but
+// the owner can remove themselves to leave the classroom.
+func (db *DBHandler) RemoveClassroomMember(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroom(w, r)
+	if !ok {
+		return
+	}
+	var user models.User
+	var target models.ClassroomMember
+	if err := db.Where("nickname = ?", r.PathValue("nickname")).First(&user).Error; err != nil ||
+		db.Where("classroom_id = ? AND user_id = ?", classroom.ID, user.ID).First(&target).Error != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	isOwner := classroom.OwnerID == member.UserID
+	allowed := target.UserID == member.UserID ||
+		(target.Role == models.ClassroomStudent && member.Role == models.ClassroomTeacher) ||
+		isOwner
+	if !allowed {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if target.UserID == classroom.OwnerID {
+		http.Error(w, "The owner can't leave their own classroom; delete it instead", http.StatusBadRequest)
+		return
+	}
+	if err := db.Unscoped().Delete(&target).Error; err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/classrooms/{classroomID}/assignments
+func (db *DBHandler) CreateAssignment(w http.ResponseWriter, r *http.Request) {
+	classroom, member, ok := db.loadClassroomAsTeacher(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Set   string     `json:"Set"` // Public ID
+		DueAt *time.Time `json:"DueAt,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Where("public_id = ?", req.Set).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	// Assigning a set shares it with the class, so teachers can only assign
+	// their own sets or public ones. A private organization set stays inside
+	// its organization.
+	if !set.IsPublic && (set.UserID != member.UserID || set.OrganizationID != nil) {
+		http.Error(w, "You can only assign your own or public sets", http.StatusForbidden)
+		return
+	}
+	var existing int64
+	db.Model(&models.ClassroomAssignment{}).Where("classroom_id = ? AND set_id = ?", classroom.ID, set.ID).Count(&existing)
+	if existing > 0 {
+		http.Error(w, "That set is already assigned to this classroom", http.StatusConflict)
+		return
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	assignment := models.ClassroomAssignment{
+		PublicID:    publicID,
+		ClassroomID: classroom.ID,
+		SetID:       set.ID,
+		DueAt:       req.DueAt,
+	}
+	if err := db.Omit("Classroom", "FlashcardSet").Create(&assignment).Error; err != nil {
+		http.Error(w, "Failed to create assignment", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(assignmentResponse{ClassroomAssignment: assignment, Set: set.PublicID, SetTitle: set.Title})
+}
+
+// PUT /api/classrooms/{classroomID}/assignments/{assignmentID}
+func (db *DBHandler) UpdateAssignment(w http.ResponseWriter, r *http.Request) {
+	classroom, _, ok := db.loadClassroomAsTeacher(w, r)
+	if !ok {
+		return
+	}
+	var assignment models.ClassroomAssignment
+	if err := db.Preload("FlashcardSet").Where("public_id = ? AND classroom_id = ?", r.PathValue("assignmentID"), classroom.ID).
+		First(&assignment).Error; err != nil {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		DueAt *time.Time `json:"DueAt"` // null removes the due date
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := db.Model(&assignment).Update("due_at", req.DueAt).Error; err != nil {
+		http.Error(w, "Failed to update assignment", http.StatusInternalServerError)
+		return
+	}
+	assignment.DueAt = req.DueAt
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignmentResponse{
+		ClassroomAssignment: assignment,
+		Set:                 assignment.FlashcardSet.PublicID,
+		SetTitle:            assignment.FlashcardSet.Title,
+	})
+}
+
+// DELETE /api/classrooms/{classroomID}/assignments/{assignmentID}
+func (db *DBHandler) DeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	classroom, _, ok := db.loadClassroomAsTeacher(w, r)
+	if !ok {
+		return
+	}
+	result := db.Unscoped().Where("public_id = ? AND classroom_id = ?", r.PathValue("assignmentID"), classroom.ID).
+		Delete(&models.ClassroomAssignment{})
+	if result.Error != nil {
+		http.Error(w, "Failed to delete assignment", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/classrooms/{classroomID}/dashboard
+//
+// Each student's progress on each assigned set: cards studied and mastered in
+// spaced repetition, mind map quiz results and Blocks games.
+func (db *DBHandler) GetClassroomDashboard(w http.ResponseWriter, r *http.Request) {
+	classroom, _, ok := db.loadClassroomAsTeacher(w, r)
+	if !ok {
+		return
+	}
+	var students []models.ClassroomMember
+	if err := db.Preload("User").Where("classroom_id = ? AND role = ?", classroom.ID, models.ClassroomStudent).
+		Order("id asc").Find(&students).Error; err != nil {
+		http.Error(w, "Failed to fetch students", http.StatusInternalServerError)
+		return
+	}
+	assignments, err := classroomAssignments(db.DB, classroom.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch assignments", http.StatusInternalServerError)
+		return
+	}
+	userIDs := make([]uint, 0, len(students))
+	for _, s := range students {
+		userIDs = append(userIDs, s.UserID)
+	}
+	setIDs := make([]uint, 0, len(assignments))
+	for _, a := range assignments {
+		setIDs = append(setIDs, a.SetID)
+	}
+
+	type key struct{ UserID, SetID uint }
+	var cardCounts []struct {
+		SetID uint
+		Cards int
+	}
+	var reviews []struct {
+		UserID       uint
+		SetID        uint
+		Studied      int
+		Mature       int
+		LastReviewed *time.Time
+	}
+	var quizzes []struct {
+		UserID   uint
+		SetID    uint
+		Attempts int
+		Best     float64
+	}
+	var games []struct {
+		UserID   uint
+		SetID    uint
+		Games    int
+		BestTime int
+		Accuracy float64
+	}
+	if len(userIDs) > 0 && len(setIDs) > 0 {
+		err := db.Model(&models.Flashcard{}).Select("set_id, COUNT(*) AS cards").
+			Where("set_id IN ?", setIDs).Group("set_id").Scan(&cardCounts).Error
+		if err == nil {
+			err = db.Model(&models.ReviewState{}).
+				Select("review_states.user_id, flashcards.set_id, "+
+					"COUNT(DISTINCT review_states.flashcard_id) AS studied, "+
+					"COUNT(DISTINCT CASE WHEN review_states.interval_days >= ? THEN review_states.flashcard_id END) AS mature, "+
+					"MAX(review_states.last_reviewed) AS last_reviewed", matureIntervalDays).
+				Joins("JOIN flashcards ON flashcards.id = review_states.flashcard_id AND flashcards.deleted_at IS NULL").
+				Where("review_states.user_id IN ? AND flashcards.set_id IN ?", userIDs, setIDs).
+				Group("review_states.user_id, flashcards.set_id").Scan(&reviews).Error
+		}
+		if err == nil {
+			err = db.Model(&models.MindMapQuizResult{}).
+				Select("user_id, flashcard_set_id AS set_id, COUNT(*) AS attempts, "+
+					"MAX(correct_answers * 1.0 / NULLIF(total_questions, 0)) AS best").
+				Where("user_id IN ? AND flashcard_set_id IN ?", userIDs, setIDs).
+				Group("user_id, flashcard_set_id").Scan(&quizzes).Error
+		}
+		if err == nil {
+			err = db.Model(&models.BlocksScore{}).
+				Select("user_id, flashcard_set_id AS set_id, COUNT(*) AS games, MIN(time_seconds) AS best_time, "+
+					"MAX(correct_attempts * 1.0 / NULLIF(total_attempts, 0)) AS accuracy").
+				Where("user_id IN ? AND flashcard_set_id IN ?", userIDs, setIDs).
+				Group("user_id, flashcard_set_id").Scan(&games).Error
+		}
+		if err != nil {
+			http.Error(w, "Failed to aggregate progress", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	cards := make(map[uint]int, len(cardCounts))
+	for _, c := range cardCounts {
+		cards[c.SetID] = c.Cards
+	}
+	type StudyProgress struct {
+		Studied      int // Cards reviewed at least once
+		Mature       int // Cards on an interval of at least three weeks
+		LastReviewed *time.Time
+	}
+	type QuizProgress struct {
+		Attempts    int
+		BestPercent int
+	}
+	type BlocksProgress struct {
+		Games           int
+		BestTimeSeconds int
+		BestAccuracy    int // Percent
+	}
+	type AssignmentProgress struct {
+		Assignment string // Public ID
+		Set        string
+		Cards      int
+		Complete   bool // Every card studied
+		Overdue    bool // Past due and not complete
+		Study      StudyProgress
+		Quizzes    QuizProgress
+		Blocks     BlocksProgress
+	}
+	progress := make(map[key]*AssignmentProgress)
+	for _, s := range students {
+		for _, a := range assignments {
+			progress[key{s.UserID, a.SetID}] = &AssignmentProgress{
+				Assignment: a.PublicID,
+				Set:        a.Set,
+				Cards:      cards[a.SetID],
+			}
+		}
+	}
+	for _, row := range reviews {
+		if p := progress[key{row.UserID, row.SetID}]; p != nil {
+			p.Study = StudyProgress{Studied: row.Studied, Mature: row.Mature, LastReviewed: row.LastReviewed}
+		}
+	}
+	for _, row := range quizzes {
+		if p := progress[key{row.UserID, row.SetID}]; p != nil {
+			p.Quizzes = QuizProgress{Attempts: row.Attempts, BestPercent: int(row.Best*100 + 0.5)}
+		}
+	}
+	for _, row := range games {
+		if p := progress[key{row.UserID, row.SetID}]; p != nil {
+			p.Blocks = BlocksProgress{Games: row.Games, BestTimeSeconds: row.BestTime, BestAccuracy: int(row.Accuracy*100 + 0.5)}
+		}
+	}
+
+	type StudentProgress struct {
+		Nickname    string
+		Assignments []AssignmentProgress
+	}
+	type Dashboard struct {
+		Classroom   string // Public ID
+		Assignments []assignmentResponse
+		Students    []StudentProgress
+	}
+	now := time.Now()
+	dashboard := Dashboard{Classroom: classroom.PublicID, Assignments: assignments, Students: []StudentProgress{}}
+	for _, s := range students {
+		student := StudentProgress{Nickname: s.User.Nickname, Assignments: []AssignmentProgress{}}
+		for _, a := range assignments {
+			p := progress[key{s.UserID, a.SetID}]
+			p.Complete = p.Cards > 0 && p.Study.Studied >= p.Cards
+			p.Overdue = !p.Complete && a.DueAt != nil && a.DueAt.Before(now)
+			student.Assignments = append(student.Assignments, *p)
+		}
+		dashboard.Students = append(dashboard.Students, student)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+// loadOwnedSet resolves the set in the path and checks the caller owns it, or
+// can edit it through their organization role.
+func (db *DBHandler) loadOwnedSet(w http.ResponseWriter, r *http.Request) (*models.FlashcardSet, bool) {
+	if _, ok := utils.GetAuth0ID(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", r.PathValue("setID")).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return &set, true
+}
+
+// loadReadableSet resolves the set in the path for anyone if it's public, or for
+// its owner, members of its organization or holders of a share link.
+func (db *DBHandler) loadReadableSet(w http.ResponseWriter, r *http.Request) (*models.FlashcardSet, bool) {
+	return db.loadSharedSet(w, r, models.ShareScopeView)
+}
+
+// loadSharedSet is loadReadableSet for share links that need a particular scope.
+func (db *DBHandler) loadSharedSet(w http.ResponseWriter, r *http.Request, scope string) (*models.FlashcardSet, bool) {
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", r.PathValue("setID")).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return nil, false
+	}
+	if set.IsPublic {
+		return &set, true
+	}
+	if db.hasSetRole(r, &set, models.OrgViewer) {
+		return &set, true
+	}
+	if !db.sharedWith(r, set.ID, 0, scope) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return &set, true
+}
+
+// conceptFilter returns the concept named by ?concept= or nil when there's no filter.
+func conceptFilter(db *gorm.DB, r *http.Request, setID uint) (*models.Concept, error) {
+	publicID := r.URL.Query().Get("concept")
+	if publicID == "" {
+		return nil, nil
+	}
+	var concept models.Concept
+	if err := db.Where("public_id = ? AND set_id = ?", publicID, setID).First(&concept).Error; err != nil {
+		return nil, err
+	}
+	return &concept, nil
+}
+
+// conceptForText finds the set's concept whose name matches a card's learning goal text.
+func conceptForText(db *gorm.DB, setID uint, text string) *uint {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	var concept models.Concept
+	if err := db.Where("set_id = ? AND LOWER(name) = LOWER(?)", setID, text).First(&concept).Error; err != nil {
+		return nil
+	}
+	return &concept.ID
+}
+
+// GET /api/sets/{setID}/concepts
+func (db *DBHandler) GetConceptsForSet(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadReadableSet(w, r)
+	if !ok {
+		return
+	}
+	var concepts []models.Concept
+	if err := db.Where("set_id = ?", set.ID).Order("position asc, id asc").Find(&concepts).Error; err != nil {
+		http.Error(w, "Failed to fetch concepts", http.StatusInternalServerError)
+		return
+	}
+	type ConceptWithCount struct {
+		models.Concept
+		CardCount int64
+	}
+	result := make([]ConceptWithCount, 0, len(concepts))
+	for _, concept := range concepts {
+		var count int64
+		if err := db.Model(&models.Flashcard{}).Where("concept_id = ?", concept.ID).Count(&count).Error; err != nil {
+			http.Error(w, "Failed to count flashcards", http.StatusInternalServerError)
+			return
+		}
+		result = append(result, ConceptWithCount{Concept: concept, CardCount: count})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GET /api/sets/{setID}/concepts/flashcards
+func (db *DBHandler) GetFlashcardsByConcept(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadReadableSet(w, r)
+	if !ok {
+		return
+	}
+	var concepts []models.Concept
+	if err := db.Where("set_id = ?", set.ID).Order("position asc, id asc").Find(&concepts).Error; err != nil {
+		http.Error(w, "Failed to fetch concepts", http.StatusInternalServerError)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID)).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	type Progress struct {
+		Total    int
+		Reviewed int
+		Mastered int
+	}
+	type ConceptGroup struct {
+		Concept    *models.Concept `json:",omitempty"` // nil for cards with no concept
+		Flashcards []models.Flashcard
+		Progress   Progress
+	}
+	groups := make([]ConceptGroup, len(concepts)+1)
+	index := make(map[uint]int, len(concepts))
+	for i := range concepts {
+		groups[i].Concept = &concepts[i]
+		groups[i].Flashcards = []models.Flashcard{}
+		index[concepts[i].ID] = i
+	}
+	unassigned := len(concepts)
+	groups[unassigned].Flashcards = []models.Flashcard{}
+	for _, fc := range flashcards {
+		i := unassigned
+		if fc.ConceptID != nil {
+			if j, ok := index[*fc.ConceptID]; ok {
+				i = j
+			}
+		}
+		groups[i].Flashcards = append(groups[i].Flashcards, fc)
+		groups[i].Progress.Total++
+		if fc.TimesReviewed > 0 {
+			groups[i].Progress.Reviewed++
+		}
+		if fc.Mastered {
+			groups[i].Progress.Mastered++
+		}
+	}
+	if len(groups[unassigned].Flashcards) == 0 {
+		groups = groups[:unassigned]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// POST /api/sets/{setID}/concepts
+func (db *DBHandler) CreateConcept(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name        string `json:"Name"`
+		Description string `json:"Description"`
+		Position    *int   `json:"Position,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 200 || len(req.Description) > 1000 {
+		http.Error(w, "Name is required and must be at most 200 characters, Description at most 1000", http.StatusBadRequest)
+		return
+	}
+	position := 0
+	if req.Position != nil {
+		position = *req.Position
+	} else {
+		// Append after the last concept
+		var last models.Concept
+		if err := db.Where("set_id = ?", set.ID).Order("position desc").First(&last).Error; err == nil {
+			position = last.Position + 1
+		}
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	concept := models.Concept{
+		SetID:       set.ID,
+		PublicID:    publicID,
+		Name:        req.Name,
+		Description: req.Description,
+		Position:    position,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("FlashcardSet").Create(&concept).Error; err != nil {
+			return err
+		}
+		// Pick up cards whose learning goal text already names this concept
+		return tx.Model(&models.Flashcard{}).
+			Where("set_id = ? AND concept_id IS NULL AND LOWER(TRIM(concept)) = LOWER(?)", set.ID, concept.Name).
+			Update("concept_id", concept.ID).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to create concept", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(concept)
+}
+
+// PUT /api/sets/{setID}/concepts/{conceptID}
+func (db *DBHandler) UpdateConcept(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var concept models.Concept
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("conceptID"), set.ID).First(&concept).Error; err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Name        *string `json:"Name,omitempty"`
+		Description *string `json:"Description,omitempty"`
+		Position    *int    `json:"Position,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil {
+		concept.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		concept.Description = *req.Description
+	}
+	if req.Position != nil {
+		concept.Position = *req.Position
+	}
+	if concept.Name == "" || len(concept.Name) > 200 || len(concept.Description) > 1000 {
+		http.Error(w, "Name is required and must be at most 200 characters, Description at most 1000", http.StatusBadRequest)
+		return
+	}
+	if err := db.Omit("FlashcardSet").Save(&concept).Error; err != nil {
+		http.Error(w, "Failed to update concept", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(concept)
+}
+
+// DELETE /api/sets/{setID}/concepts/{conceptID}
+func (db *DBHandler) DeleteConcept(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var concept models.Concept
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("conceptID"), set.ID).First(&concept).Error; err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return
+	}
+	// Cards stay in the set, just ungrouped
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Flashcard{}).Where("concept_id = ?", concept.ID).Update("concept_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&concept).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete concept", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/sets/{setID}/concepts/{conceptID}/flashcards
+func (db *DBHandler) AssignFlashcardsToConcept(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var concept models.Concept
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("conceptID"), set.ID).First(&concept).Error; err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Flashcards []string `json:"Flashcards"` // Flashcard public IDs
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Flashcards) == 0 {
+		http.Error(w, "Flashcards is required", http.StatusBadRequest)
+		return
+	}
+	cards := db.Model(&models.Flashcard{}).Where("set_id = ? AND public_id IN ?", set.ID, req.Flashcards)
+	var count int64
+	if err := cards.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	if count != int64(len(req.Flashcards)) {
+		http.Error(w, "Some flashcards were not found in set", http.StatusNotFound)
+		return
+	}
+	if err := cards.Session(&gorm.Session{}).Update("concept_id", concept.ID).Error; err != nil {
+		http.Error(w, "Failed to assign flashcards", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
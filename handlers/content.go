@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+// validateFlashcardContent defaults a card to a plain text basic card and
+// checks both sides parse in its format. Cloze cards also need well formed
+// markers in their solution.
+func validateFlashcardContent(fc *models.Flashcard) error {
+	if fc.Format == "" {
+		fc.Format = utils.FormatPlain
+	}
+	if fc.Type == "" {
+		fc.Type = models.CardBasic
+	}
+	if err := utils.ValidateContent(fc.Format, fc.Language, fc.Term); err != nil {
+		return err
+	}
+	if err := utils.ValidateContent(fc.Format, fc.Language, fc.Solution); err != nil {
+		return err
+	}
+	switch fc.Type {
+	case models.CardBasic:
+		return nil
+	case models.CardCloze:
+		return utils.ValidateCloze(fc.Solution)
+	default:
+		return fmt.Errorf("unknown card type %q", fc.Type)
+	}
+}
+
+// GET /api/sets/{setID}/flashcards/{flashcardID}/render
+func (db *DBHandler) RenderFlashcard(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadReadableSet(w, r)
+	if !ok {
+		return
+	}
+	var flashcard models.Flashcard
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("flashcardID"), set.ID).First(&flashcard).Error; err != nil {
+		http.Error(w, "Flashcard not found", http.StatusNotFound)
+		return
+	}
+	type RenderedCloze struct {
+		Item         string
+		QuestionHTML string
+		AnswerHTML   string
+	}
+	type RenderedFlashcard struct {
+		PublicID     string
+		Type         string
+		Format       string
+		Language     string `json:",omitempty"`
+		TermHTML     string
+		SolutionHTML string
+		Cloze        []RenderedCloze `json:",omitempty"` // One variant per cloze number
+	}
+	rendered := RenderedFlashcard{
+		PublicID:     flashcard.PublicID,
+		Type:         flashcard.Type,
+		Format:       flashcard.Format,
+		Language:     flashcard.Language,
+		TermHTML:     utils.RenderContent(flashcard.Format, flashcard.Language, flashcard.Term),
+		SolutionHTML: utils.RenderContent(flashcard.Format, flashcard.Language, flashcard.Solution),
+	}
+	if flashcard.Type == models.CardCloze {
+		rendered.SolutionHTML = utils.RenderContent(flashcard.Format, flashcard.Language, utils.ClozeAnswer(flashcard.Solution))
+		deletions, _ := utils.ParseCloze(flashcard.Solution)
+		for _, index := range utils.ClozeIndexes(deletions) {
+			rendered.Cloze = append(rendered.Cloze, RenderedCloze{
+				Item:         utils.ClozeItem(index),
+				QuestionHTML: utils.RenderCloze(flashcard.Format, flashcard.Language, flashcard.Solution, index, false),
+				AnswerHTML:   utils.RenderCloze(flashcard.Format, flashcard.Language, flashcard.Solution, index, true),
+			})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rendered)
+}
+
+// POST /api/render
+func (db *DBHandler) RenderPreview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Format   string `json:"Format"`
+		Language string `json:"Language"`
+		Text     string `json:"Text"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Format == "" {
+		req.Format = utils.FormatPlain
+	}
+	if err := utils.ValidateContent(req.Format, req.Language, req.Text); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct{ HTML string }{utils.RenderContent(req.Format, req.Language, req.Text)})
+}
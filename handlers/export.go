@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+const (
+	// exportLifetime is how long a finished export can be downloaded
+	exportLifetime = 7 * 24 * time.Hour
+	// exportTimeout is how long an export may stay pending. Older pending
+	// exports were lost to a restart and are marked failed.
+	exportTimeout = time.Hour
+)
+
+// GET /api/me/exports
+func (db *DBHandler) GetDataExports(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var exports []models.DataExport
+	if err := db.Where("user_id = ?", user.ID).Order("id desc").Find(&exports).Error; err != nil {
+		http.Error(w, "Failed to fetch exports", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exports)
+}
+
+// POST /api/me/exports
+//
+// Starts building a zip of everything the caller has stored. It's built in
+// the background; poll GET /api/me/exports until it's ready, then download it.
+func (db *DBHandler) CreateDataExport(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var pending int64
+	db.Model(&models.DataExport{}).Where("user_id = ? AND status = ? AND created_at > ?", user.ID, models.ExportPending, time.Now().Add(-exportTimeout)).
+		Count(&pending)
+	if pending > 0 {
+		http.Error(w, "An export is already being prepared", http.StatusConflict)
+		return
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	export := models.DataExport{PublicID: publicID, UserID: user.ID, Status: models.ExportPending}
+	if err := db.Omit("User").Create(&export).Error; err != nil {
+		http.Error(w, "Failed to start export", http.StatusInternalServerError)
+		return
+	}
+	go db.buildDataExport(export, *user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(export)
+}
+
+// GET /api/me/exports/{exportID}/download
+func (db *DBHandler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var export models.DataExport
+	if err := db.Where("public_id = ? AND user_id = ?", r.PathValue("exportID"), user.ID).First(&export).Error; err != nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	if export.Status != models.ExportReady {
+		http.Error(w, "Export is not ready", http.StatusConflict)
+		return
+	}
+	if export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		http.Error(w, "Export has expired", http.StatusGone)
+		return
+	}
+	body, err := db.Storage.Get(r.Context(), export.StorageKey)
+	if err != nil {
+		http.Error(w, "Export file not found", http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="nodebook-export-%s.zip"`, export.CreatedAt.Format("2006-01-02")))
+	io.Copy(w, body)
+}
+
+// buildDataExport writes the zip for a pending export to storage and marks
+// it ready, or failed.
+func (db *DBHandler) buildDataExport(export models.DataExport, user models.User) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := db.writeDataExport(ctx, zw, &user)
+	if err == nil {
+		err = zw.Close()
+	}
+	key := "exports/" + export.PublicID + ".zip"
+	if err == nil {
+		err = db.Storage.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/zip")
+	}
+	if err != nil {
+		log.Printf("buildDataExport: Failed to build export %s for userID=%d: %v", export.PublicID, user.ID, err)
+		db.Model(&export).Update("status", models.ExportFailed)
+		return
+	}
+	now := time.Now()
+	expires := now.Add(exportLifetime)
+	db.Model(&export).Updates(map[string]any{
+		"status":       models.ExportReady,
+		"size":         buf.Len(),
+		"storage_key":  key,
+		"completed_at": now,
+		"expires_at":   expires,
+	})
+}
+
+func writeExportJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeDataExport adds the user's profile, sets with their cards and media,
+// mind maps, review history, scores and path explanations to zw.
+func (db *DBHandler) writeDataExport(ctx context.Context, zw *zip.Writer, user *models.User) error {
+	if err := writeExportJSON(zw, "profile.json", newProfileResponse(user)); err != nil {
+		return err
+	}
+
+	type exportedSet struct {
+		models.FlashcardSet
+		Sections []models.Section
+		Concepts []models.Concept
+		Media    []models.FlashcardMedia
+	}
+	var sets []models.FlashcardSet
+	if err := db.Preload("Flashcards", orderFlashcards).Where("user_id = ?", user.ID).Find(&sets).Error; err != nil {
+		return err
+	}
+	for _, set := range sets {
+		exported := exportedSet{FlashcardSet: set}
+		if err := db.Where("set_id = ?", set.ID).Order("position asc").Find(&exported.Sections).Error; err != nil {
+			return err
+		}
+		if err := db.Where("set_id = ?", set.ID).Find(&exported.Concepts).Error; err != nil {
+			return err
+		}
+		if err := db.Joins("JOIN flashcards ON flashcards.id = flashcard_media.flashcard_id").
+			Where("flashcards.set_id = ?", set.ID).Find(&exported.Media).Error; err != nil {
+			return err
+		}
+		if err := writeExportJSON(zw, "sets/"+set.PublicID+".json", exported); err != nil {
+			return err
+		}
+		for _, media := range exported.Media {
+			if err := db.writeExportMedia(ctx, zw, media); err != nil {
+				return err
+			}
+		}
+	}
+
+	type exportedMindMap struct {
+		models.MindMap
+		Set     string // Public ID
+		Layouts []models.MindMapNodeLayout
+	}
+	var mindMaps []models.MindMap
+	if err := db.Preload("Connections").Preload("Nodes").Where("user_id = ?", user.ID).Find(&mindMaps).Error; err != nil {
+		return err
+	}
+	for _, mindMap := range mindMaps {
+		exported := exportedMindMap{MindMap: mindMap}
+		var set models.FlashcardSet
+		if err := db.Unscoped().Select("public_id").First(&set, mindMap.SetID).Error; err == nil {
+			exported.Set = set.PublicID
+		}
+		if err := db.Where("mind_map_id = ?", mindMap.ID).Find(&exported.Layouts).Error; err != nil {
+			return err
+		}
+		if err := writeExportJSON(zw, "mindmaps/"+mindMap.PublicID+".json", exported); err != nil {
+			return err
+		}
+	}
+
+	// Review history covers cards in other people's sets too, so each row
+	// names its set and card rather than relying on the files above
+	type exportedReview struct {
+		Set          string
+		Flashcard    string
+		Term         string
+		Direction    string
+		Item         string `json:",omitempty"`
+		EaseFactor   float64
+		IntervalDays int
+		Repetitions  int
+		Lapses       int
+		DueAt        time.Time
+		LastReviewed *time.Time
+		FirstStudied time.Time
+	}
+	var states []models.ReviewState
+	if err := db.Preload("Flashcard.FlashcardSet").Where("user_id = ?", user.ID).Order("id asc").Find(&states).Error; err != nil {
+		return err
+	}
+	reviews := make([]exportedReview, 0, len(states))
+	for _, s := range states {
+		reviews = append(reviews, exportedReview{
+			Set:          s.Flashcard.FlashcardSet.PublicID,
+			Flashcard:    s.Flashcard.PublicID,
+			Term:         s.Flashcard.Term,
+			Direction:    s.Direction,
+			Item:         s.Item,
+			EaseFactor:   s.EaseFactor,
+			IntervalDays: s.IntervalDays,
+			Repetitions:  s.Repetitions,
+			Lapses:       s.Lapses,
+			DueAt:        s.DueAt,
+			LastReviewed: s.LastReviewed,
+			FirstStudied: s.CreatedAt,
+		})
+	}
+	if err := writeExportJSON(zw, "review_history.json", reviews); err != nil {
+		return err
+	}
+
+	type exportedBlocksScore struct {
+		Set             string
+		SetTitle        string
+		TimeSeconds     int
+		CorrectAttempts int
+		TotalAttempts   int
+		PlayedAt        time.Time
+	}
+	var scores []models.BlocksScore
+	if err := db.Preload("FlashcardSet").Where("user_id = ?", user.ID).Order("played_at asc").Find(&scores).Error; err != nil {
+		return err
+	}
+	blocks := make([]exportedBlocksScore, 0, len(scores))
+	for _, s := range scores {
+		blocks = append(blocks, exportedBlocksScore{
+			Set:             s.FlashcardSet.PublicID,
+			SetTitle:        s.FlashcardSet.Title,
+			TimeSeconds:     s.TimeSeconds,
+			CorrectAttempts: s.CorrectAttempts,
+			TotalAttempts:   s.TotalAttempts,
+			PlayedAt:        s.PlayedAt,
+		})
+	}
+	if err := writeExportJSON(zw, "blocks_scores.json", blocks); err != nil {
+		return err
+	}
+
+	type exportedQuizResult struct {
+		Set            string
+		MindMap        string
+		QuizType       string
+		CorrectAnswers int
+		TotalQuestions int
+		PlayedAt       time.Time
+	}
+	var results []models.MindMapQuizResult
+	if err := db.Preload("FlashcardSet").Preload("MindMap").Where("user_id = ?", user.ID).Order("played_at asc").Find(&results).Error; err != nil {
+		return err
+	}
+	quizzes := make([]exportedQuizResult, 0, len(results))
+	for _, q := range results {
+		quizzes = append(quizzes, exportedQuizResult{
+			Set:            q.FlashcardSet.PublicID,
+			MindMap:        q.MindMap.PublicID,
+			QuizType:       q.QuizType,
+			CorrectAnswers: q.CorrectAnswers,
+			TotalQuestions: q.TotalQuestions,
+			PlayedAt:       q.PlayedAt,
+		})
+	}
+	if err := writeExportJSON(zw, "mindmap_quiz_results.json", quizzes); err != nil {
+		return err
+	}
+
+	type exportedPathExplanation struct {
+		MindMap      string
+		From         string
+		FromTerm     string
+		To           string
+		ToTerm       string
+		Explanation  string
+		StepsCovered int
+		TotalSteps   int
+		CreatedAt    time.Time
+	}
+	var written []models.MindMapPathExplanation
+	unscoped := func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }
+	if err := db.Preload("MindMap").Preload("FromFlashcard", unscoped).Preload("ToFlashcard", unscoped).
+		Where("user_id = ?", user.ID).Order("created_at asc").Find(&written).Error; err != nil {
+		return err
+	}
+	explanations := make([]exportedPathExplanation, 0, len(written))
+	for _, e := range written {
+		explanations = append(explanations, exportedPathExplanation{
+			MindMap:      e.MindMap.PublicID,
+			From:         e.FromFlashcard.PublicID,
+			FromTerm:     e.FromFlashcard.Term,
+			To:           e.ToFlashcard.PublicID,
+			ToTerm:       e.ToFlashcard.Term,
+			Explanation:  e.Explanation,
+			StepsCovered: e.StepsCovered,
+			TotalSteps:   e.TotalSteps,
+			CreatedAt:    e.CreatedAt,
+		})
+	}
+	return writeExportJSON(zw, "mindmap_path_explanations.json", explanations)
+}
+
+// writeExportMedia copies a media file's original into the zip. A missing
+// blob is skipped so one lost file doesn't fail the whole export.
+func (db *DBHandler) writeExportMedia(ctx context.Context, zw *zip.Writer, media models.FlashcardMedia) error {
+	body, err := db.Storage.Get(ctx, media.StorageKey)
+	if err != nil {
+		log.Printf("writeExportMedia: Skipping media %s: %v", media.PublicID, err)
+		return nil
+	}
+	defer body.Close()
+	name := "media/" + media.PublicID
+	if exts, err := mime.ExtensionsByType(media.ContentType); err == nil && len(exts) > 0 {
+		name += exts[0]
+	}
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("copying media %s: %w", media.PublicID, err)
+	}
+	return nil
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/storage"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"gorm.io/gorm"
 
@@ -13,6 +15,8 @@ import (
 
 type DBHandler struct {
 	*gorm.DB
+	Storage        storage.Store // Uploaded media
+	MediaURLSecret []byte        // Signs media download links
 }
 
 func (db *DBHandler) GetFlashcardByID(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +46,7 @@ func (db *DBHandler) GetFlashcardByID(w http.ResponseWriter, r *http.Request) {
 
 func (db *DBHandler) CreateFlashCard(w http.ResponseWriter, r *http.Request) {
 
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -62,7 +66,7 @@ func (db *DBHandler) CreateFlashCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if auth0ID != user.Auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Status Forbidden", http.StatusForbidden)
 		return
 	}
@@ -74,6 +78,10 @@ func (db *DBHandler) CreateFlashCard(w http.ResponseWriter, r *http.Request) {
 		Term         string
 		Solution     string
 		LearningGoal string `json:"concept"`
+		ConceptID    string `json:"conceptID"`
+		Format       string `json:"format"`
+		Language     string `json:"language"`
+		Type         string `json:"type"`
 	}
 
 	var FlashcardRequest FlashcardRequestData
@@ -97,6 +105,24 @@ func (db *DBHandler) CreateFlashCard(w http.ResponseWriter, r *http.Request) {
 		Concept:  FlashcardRequest.LearningGoal,
 		PublicID: publicID,
 		SetID:    set.ID,
+		Format:   FlashcardRequest.Format,
+		Language: FlashcardRequest.Language,
+		Type:     FlashcardRequest.Type,
+		Position: nextCardPosition(db.DB, set.ID),
+	}
+	if err := validateFlashcardContent(&flashcard); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if FlashcardRequest.ConceptID != "" {
+		var concept models.Concept
+		if err := db.Where("public_id = ? AND set_id = ?", FlashcardRequest.ConceptID, set.ID).First(&concept).Error; err != nil {
+			http.Error(w, "Concept not found in set", http.StatusNotFound)
+			return
+		}
+		flashcard.ConceptID = &concept.ID
+	} else {
+		flashcard.ConceptID = conceptForText(db.DB, set.ID, flashcard.Concept)
 	}
 
 	if err := db.Create(&flashcard).Error; err != nil {
@@ -112,7 +138,7 @@ func (db *DBHandler) UpdateFlashCardByID(w http.ResponseWriter, r *http.Request)
 	setID := r.PathValue("setID")
 	flashcardID := r.PathValue("flashcardID")
 
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -130,7 +156,7 @@ func (db *DBHandler) UpdateFlashCardByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if owner.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Forbidden: You do not own this set", http.StatusForbidden)
 		return
 	}
@@ -147,6 +173,11 @@ func (db *DBHandler) UpdateFlashCardByID(w http.ResponseWriter, r *http.Request)
 		Term     *string `json:"term,omitempty"`
 		Solution *string `json:"solution,omitempty"`
 		Concept  *string `json:"concept,omitempty"`
+		// Public ID of the concept to group the card under, "" to ungroup
+		ConceptID *string `json:"conceptID,omitempty"`
+		Format    *string `json:"format,omitempty"`
+		Language  *string `json:"language,omitempty"`
+		Type      *string `json:"type,omitempty"`
 	}
 	var req FlashcardUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -163,6 +194,34 @@ func (db *DBHandler) UpdateFlashCardByID(w http.ResponseWriter, r *http.Request)
 	}
 	if req.Concept != nil {
 		flashcard.Concept = *req.Concept
+		if flashcard.ConceptID == nil {
+			flashcard.ConceptID = conceptForText(db.DB, set.ID, flashcard.Concept)
+		}
+	}
+	if req.ConceptID != nil {
+		flashcard.ConceptID = nil
+		if *req.ConceptID != "" {
+			var concept models.Concept
+			if err := db.Where("public_id = ? AND set_id = ?", *req.ConceptID, set.ID).First(&concept).Error; err != nil {
+				http.Error(w, "Concept not found in set", http.StatusNotFound)
+				return
+			}
+			flashcard.ConceptID = &concept.ID
+		}
+	}
+
+	if req.Format != nil {
+		flashcard.Format = *req.Format
+	}
+	if req.Language != nil {
+		flashcard.Language = *req.Language
+	}
+	if req.Type != nil {
+		flashcard.Type = *req.Type
+	}
+	if err := validateFlashcardContent(&flashcard); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Save the updated flashcard
@@ -180,7 +239,7 @@ func (db *DBHandler) DeleteFlashCardByID(w http.ResponseWriter, r *http.Request)
 	setID := r.PathValue("setID")
 	flashcardID := r.PathValue("flashcardID")
 
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Not authorized", http.StatusForbidden)
 		return
@@ -198,7 +257,7 @@ func (db *DBHandler) DeleteFlashCardByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if auth0ID != setOwner.Auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Not authorized", http.StatusForbidden)
 		return
 	}
@@ -213,6 +272,12 @@ func (db *DBHandler) DeleteFlashCardByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Attachments go with the card
+	cardIDs := db.Unscoped().Model(&models.Flashcard{}).Select("id").Where("public_id = ? AND set_id = ?", flashcardID, set.ID)
+	if err := db.deleteCardMedia(r.Context(), cardIDs); err != nil {
+		log.Printf("DeleteFlashCardByID: Failed to delete media for flashcard %s: %v", flashcardID, err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -231,17 +296,26 @@ func (db *DBHandler) GetFlashcardsForSet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !set.IsPublic {
-		// If not public, check authentication and ownership
-		auth0ID, ok := utils.GetAuth0ID(r)
-		if !ok || user.Auth0ID != auth0ID {
+	if !set.IsPublic && !db.sharedWith(r, set.ID, 0, models.ShareScopeView) {
+		// If not public or shared, check authentication and ownership
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 	}
 
+	concept, err := conceptFilter(db.DB, r, set.ID)
+	if err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return
+	}
+	query := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID))
+	if concept != nil {
+		query = query.Where("flashcards.concept_id = ?", concept.ID)
+	}
+
 	var flashcards []models.Flashcard
-	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+	if err := query.Find(&flashcards).Error; err != nil {
 		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
 		return
 	}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/andrewpaige1/nodebook-api/models"
 	"github.com/andrewpaige1/nodebook-api/utils"
 	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GET /api/sets/{setID}/mindmaps
@@ -23,13 +26,12 @@ func (db *DBHandler) GetMindMapsForSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	auth0ID, ok := utils.GetAuth0ID(r)
 	var mindMaps []models.MindMap
 
-	query := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Where("set_id = ?", set.ID)
+	query := preloadConnections(db.DB, r).Preload("Nodes").Where("set_id = ?", set.ID)
 
-	if !(ok && set.User.Auth0ID == auth0ID) {
-		// Only show public mindmaps if not owner
+	if !db.hasSetRole(r, &set, models.OrgViewer) && !db.sharedWith(r, set.ID, 0, models.ShareScopeView) {
+		// Only show public mindmaps if not owner or holding a link to the set
 		query = query.Where("is_public = ?", true)
 	}
 	if err := query.Find(&mindMaps).Error; err != nil {
@@ -74,7 +76,7 @@ func (db *DBHandler) GetMindMapByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var mindMap models.MindMap
-	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+	if err := preloadConnections(db.DB, r).Preload("Nodes").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
 		http.Error(w, "MindMap not found in set", http.StatusNotFound)
 		return
 	}
@@ -92,19 +94,19 @@ func (db *DBHandler) GetMindMapByID(w http.ResponseWriter, r *http.Request) {
 		NodeLayouts: nodeLayouts,
 	}
 
-	if mindMap.IsPublic {
+	if mindMap.IsPublic || db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
 		return
 	}
 	// Private: check authentication and ownership
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if set.User.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgViewer) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -115,7 +117,7 @@ func (db *DBHandler) GetMindMapByID(w http.ResponseWriter, r *http.Request) {
 
 // POST /api/sets/{setID}/mindmaps
 func (db *DBHandler) CreateMindMap(w http.ResponseWriter, r *http.Request) {
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -141,7 +143,7 @@ func (db *DBHandler) CreateMindMap(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Set not found", http.StatusNotFound)
 		return
 	}
-	if set.User.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -156,6 +158,8 @@ func (db *DBHandler) CreateMindMap(w http.ResponseWriter, r *http.Request) {
 		UserID:   set.UserID,
 		IsPublic: req.IsPublic,
 		PublicID: publicID,
+
+		OrganizationID: set.OrganizationID,
 	}
 
 	tx := db.Begin()
@@ -176,7 +180,7 @@ func (db *DBHandler) CreateMindMap(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Preload associated data for the response
-	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").First(&mindMap, mindMap.ID).Error; err != nil {
+	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Preload("Nodes").First(&mindMap, mindMap.ID).Error; err != nil {
 		http.Error(w, "Error retrieving created mind map", http.StatusInternalServerError)
 		return
 	}
@@ -190,7 +194,7 @@ func (db *DBHandler) CreateMindMap(w http.ResponseWriter, r *http.Request) {
 func (db *DBHandler) UpdateMindMapByID(w http.ResponseWriter, r *http.Request) {
 	setID := r.PathValue("setID")
 	mindMapID := r.PathValue("mindMapID")
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -205,11 +209,11 @@ func (db *DBHandler) UpdateMindMapByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var mindMap models.MindMap
-	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Preload("Nodes").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
 		http.Error(w, "MindMap not found in set", http.StatusNotFound)
 		return
 	}
-	if set.User.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -231,13 +235,14 @@ func (db *DBHandler) UpdateMindMapByID(w http.ResponseWriter, r *http.Request) {
 		updated = true
 	}
 	if updated {
-		if err := db.Save(&mindMap).Error; err != nil {
+		// Only write the edited columns so a concurrent graph edit's Seq isn't overwritten
+		if err := db.Model(&mindMap).Select("Title", "IsPublic").Updates(&mindMap).Error; err != nil {
 			http.Error(w, "Failed to update mind map", http.StatusInternalServerError)
 			return
 		}
 	}
 	// Reload connections and node layouts for response
-	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Where("id = ? AND set_id = ?", mindMap.ID, set.ID).First(&mindMap).Error; err != nil {
+	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Preload("Nodes").Where("id = ? AND set_id = ?", mindMap.ID, set.ID).First(&mindMap).Error; err != nil {
 		http.Error(w, "Failed to reload mind map", http.StatusInternalServerError)
 		return
 	}
@@ -263,7 +268,7 @@ func (db *DBHandler) UpdateMindMapByID(w http.ResponseWriter, r *http.Request) {
 func (db *DBHandler) DeleteMindMapByID(w http.ResponseWriter, r *http.Request) {
 	setID := r.PathValue("setID")
 	mindMapID := r.PathValue("mindMapID")
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -278,16 +283,12 @@ func (db *DBHandler) DeleteMindMapByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var mindMap models.MindMap
-	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+	if err := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target").Preload("Nodes").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
 		http.Error(w, "MindMap not found in set", http.StatusNotFound)
 		return
 	}
 
-	var user models.User
-	if err := db.Where("id = ?", set.UserID).First(&user).Error; err != nil {
-		http.Error(w, "User not found for mindmap", http.StatusNotFound)
-	}
-	if user.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -315,8 +316,8 @@ func (db *DBHandler) GetMindMapsForUser(w http.ResponseWriter, r *http.Request)
 	auth0ID, ok := utils.GetAuth0ID(r)
 
 	var mindMaps []models.MindMap
-	query := db.Preload("Connections").Preload("Connections.Source").Preload("Connections.Target")
-	query = query.Where("user_id = ?", user.ID)
+	query := preloadConnections(db.DB, r).Preload("Nodes")
+	query = query.Where("user_id = ?", user.ID).Scopes(tenantScope(nil))
 
 	if !(ok && user.Auth0ID == auth0ID) {
 		query = query.Where("is_public = ?", true)
@@ -362,7 +363,7 @@ func (db *DBHandler) GetMindMapsForUser(w http.ResponseWriter, r *http.Request)
 func (db *DBHandler) UpdateMindMapLayouts(w http.ResponseWriter, r *http.Request) {
 	setID := r.PathValue("setID")
 	mindMapID := r.PathValue("mindMapID")
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -382,14 +383,13 @@ func (db *DBHandler) UpdateMindMapLayouts(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var user models.User
-	if err := db.Where("id = ?", set.UserID).First(&user).Error; err != nil {
-		http.Error(w, "User not found for mind map", http.StatusNotFound)
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
-
-	if user.Auth0ID != auth0ID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	// The edit is attributed to whoever made it, not the set's creator
+	user, ok := db.currentUser(w, r)
+	if !ok {
 		return
 	}
 	// Request struct matching frontend payload
@@ -400,6 +400,7 @@ func (db *DBHandler) UpdateMindMapLayouts(w http.ResponseWriter, r *http.Request
 		XPosition   float64 `json:"XPosition"`
 		YPosition   float64 `json:"YPosition"`
 		Data        string  `json:"Data"`
+		GroupID     *uint   `json:"GroupID,omitempty"`
 	}
 	var reqLayouts []NodeLayoutRequest
 	if err := json.NewDecoder(r.Body).Decode(&reqLayouts); err != nil {
@@ -407,25 +408,58 @@ func (db *DBHandler) UpdateMindMapLayouts(w http.ResponseWriter, r *http.Request
 		return
 	}
 	//fmt.Println("req layouts", reqLayouts)
-	// Delete existing layouts for this mindmap
-	if err := db.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapNodeLayout{}).Error; err != nil {
-		http.Error(w, "Failed to clear old node layouts", http.StatusInternalServerError)
-		return
-	}
-	// Insert new layouts
+	layouts := make([]models.MindMapNodeLayout, 0, len(reqLayouts))
 	for _, req := range reqLayouts {
-		layout := models.MindMapNodeLayout{
+		layouts = append(layouts, models.MindMapNodeLayout{
 			MindMapID:   mindMap.ID,
 			FlashcardID: req.FlashcardID,
 			XPosition:   req.XPosition,
 			YPosition:   req.YPosition,
 			Data:        req.Data,
+			GroupID:     req.GroupID,
+		})
+	}
+	// Replace all layouts in one transaction so a failure can't leave the map empty
+	op := mindMapOp{Op: opReplace, Path: "/nodes"}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMindMap(tx, &mindMap); err != nil {
+			return err
 		}
-		if err := db.Create(&layout).Error; err != nil {
-			http.Error(w, "Failed to save node layout", http.StatusInternalServerError)
-			return
+		// The same checks as the patch API, so a layout can't point at another
+		// set's card or another map's group
+		for _, layout := range layouts {
+			if err := flashcardInSet(tx, layout.FlashcardID, mindMap.SetID); err != nil {
+				return err
+			}
+			if layout.GroupID != nil {
+				if err := groupInMap(tx, *layout.GroupID, mindMap.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapNodeLayout{}).Error; err != nil {
+			return err
+		}
+		if len(layouts) > 0 {
+			if err := tx.Create(&layouts).Error; err != nil {
+				return err
+			}
+		}
+		op.Value, _ = json.Marshal(layouts)
+		if err := recordMindMapOp(tx, &mindMap, user.ID, "", &op); err != nil {
+			return err
 		}
+		return snapshotMindMap(tx, &mindMap, user.ID, snapshotReasonLayouts)
+	})
+	if errors.Is(err, errInvalidOp) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save node layouts", http.StatusInternalServerError)
+		return
 	}
+	mindMapEvents.publishOps(mindMap.ID, []mindMapOp{op})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -433,7 +467,7 @@ func (db *DBHandler) UpdateMindMapLayouts(w http.ResponseWriter, r *http.Request
 func (db *DBHandler) UpdateMindMapConnections(w http.ResponseWriter, r *http.Request) {
 	setID := r.PathValue("setID")
 	mindMapID := r.PathValue("mindMapID")
-	auth0ID, ok := utils.GetAuth0ID(r)
+	_, ok := utils.GetAuth0ID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
@@ -452,27 +486,65 @@ func (db *DBHandler) UpdateMindMapConnections(w http.ResponseWriter, r *http.Req
 		http.Error(w, "MindMap not found in set", http.StatusNotFound)
 		return
 	}
-	if set.User.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
 	var connections []models.MindMapConnection
 	if err := json.NewDecoder(r.Body).Decode(&connections); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	// Delete existing connections for this mindmap
-	if err := db.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapConnection{}).Error; err != nil {
-		http.Error(w, "Failed to clear old connections", http.StatusInternalServerError)
+	vocabulary, err := relationshipVocabulary(db.DB, set.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch relationship types", http.StatusInternalServerError)
 		return
 	}
-	// Insert new connections
-	for _, conn := range connections {
-		conn.MindMapID = mindMap.ID
-		if err := db.Create(&conn).Error; err != nil {
-			http.Error(w, "Failed to save connection", http.StatusInternalServerError)
+	for i := range connections {
+		connections[i].ID = 0
+		connections[i].MindMapID = mindMap.ID
+		if err := typeConnection(vocabulary, &connections[i]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
+	// Replace all connections in one transaction so a failure can't leave the map empty
+	op := mindMapOp{Op: opReplace, Path: "/edges"}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMindMap(tx, &mindMap); err != nil {
+			return err
+		}
+		for i := range connections {
+			if err := validateEdgeEndpoints(tx, &mindMap, &connections[i]); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapConnection{}).Error; err != nil {
+			return err
+		}
+		if len(connections) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&connections).Error; err != nil {
+				return err
+			}
+		}
+		op.Value, _ = json.Marshal(connections)
+		if err := recordMindMapOp(tx, &mindMap, user.ID, "", &op); err != nil {
+			return err
+		}
+		return snapshotMindMap(tx, &mindMap, user.ID, snapshotReasonConnections)
+	})
+	if errors.Is(err, errInvalidOp) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save connections", http.StatusInternalServerError)
+		return
+	}
+	mindMapEvents.publishOps(mindMap.ID, []mindMapOp{op})
 	w.WriteHeader(http.StatusNoContent)
 }
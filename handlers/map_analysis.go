@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+// maxReportedCycles caps how many cycles the analysis returns so a densely
+// linked map can't blow up the response.
+const maxReportedCycles = 50
+
+// cardRef identifies a flashcard in graph responses.
+type cardRef struct {
+	FlashcardID uint
+	PublicID    string
+	Term        string
+}
+
+func newCardRef(id uint, cardsByID map[uint]models.Flashcard) cardRef {
+	fc := cardsByID[id]
+	return cardRef{FlashcardID: id, PublicID: fc.PublicID, Term: fc.Term}
+}
+
+type graphEdge struct {
+	To         uint
+	Connection *models.MindMapConnection
+}
+
+// conceptGraph is the directed graph of flashcards formed by a mind map's connections.
+type conceptGraph struct {
+	Nodes []uint
+	Out   map[uint][]graphEdge
+	In    map[uint][]graphEdge
+}
+
+// cardConnections filters out connections that end at a non-flashcard node.
+func cardConnections(connections []models.MindMapConnection) []models.MindMapConnection {
+	var result []models.MindMapConnection
+	for _, conn := range connections {
+		if conn.SourceID != 0 && conn.TargetID != 0 {
+			result = append(result, conn)
+		}
+	}
+	return result
+}
+
+func newConceptGraph(connections []models.MindMapConnection, layouts []models.MindMapNodeLayout) *conceptGraph {
+	g := &conceptGraph{
+		Out: make(map[uint][]graphEdge),
+		In:  make(map[uint][]graphEdge),
+	}
+	seen := make(map[uint]bool)
+	addNode := func(id uint) {
+		if id == 0 || seen[id] {
+			return
+		}
+		seen[id] = true
+		g.Nodes = append(g.Nodes, id)
+	}
+
+	for _, layout := range layouts {
+		addNode(layout.FlashcardID)
+	}
+	for i := range connections {
+		conn := &connections[i]
+		if conn.SourceID == 0 || conn.TargetID == 0 {
+			continue
+		}
+		addNode(conn.SourceID)
+		addNode(conn.TargetID)
+		g.Out[conn.SourceID] = append(g.Out[conn.SourceID], graphEdge{To: conn.TargetID, Connection: conn})
+		g.In[conn.TargetID] = append(g.In[conn.TargetID], graphEdge{To: conn.SourceID, Connection: conn})
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i] < g.Nodes[j] })
+	return g
+}
+
+// neighbours returns the nodes adjacent to id ignoring edge direction.
+func (g *conceptGraph) neighbours(id uint) []uint {
+	var result []uint
+	seen := make(map[uint]bool)
+	for _, e := range g.Out[id] {
+		if !seen[e.To] && e.To != id {
+			seen[e.To] = true
+			result = append(result, e.To)
+		}
+	}
+	for _, e := range g.In[id] {
+		if !seen[e.To] && e.To != id {
+			seen[e.To] = true
+			result = append(result, e.To)
+		}
+	}
+	return result
+}
+
+// components returns the weakly connected components, largest first.
+func (g *conceptGraph) components() [][]uint {
+	visited := make(map[uint]bool)
+	var components [][]uint
+	for _, start := range g.Nodes {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		component := []uint{start}
+		queue := []uint{start}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, next := range g.neighbours(current) {
+				if !visited[next] {
+					visited[next] = true
+					component = append(component, next)
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Slice(component, func(i, j int) bool { return component[i] < component[j] })
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+	return components
+}
+
+// betweenness computes normalised betweenness centrality with Brandes' algorithm,
+// treating connections as undirected since learners draw them in either direction.
+func (g *conceptGraph) betweenness() map[uint]float64 {
+	centrality := make(map[uint]float64, len(g.Nodes))
+	for _, id := range g.Nodes {
+		centrality[id] = 0
+	}
+	for _, s := range g.Nodes {
+		var stack []uint
+		predecessors := make(map[uint][]uint)
+		sigma := map[uint]float64{s: 1}
+		dist := map[uint]int{s: 0}
+		queue := []uint{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range g.neighbours(v) {
+				if _, ok := dist[w]; !ok {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					predecessors[w] = append(predecessors[w], v)
+				}
+			}
+		}
+
+		delta := make(map[uint]float64)
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range predecessors[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				centrality[w] += delta[w]
+			}
+		}
+	}
+
+	// Each undirected path was counted from both ends
+	n := float64(len(g.Nodes))
+	for id := range centrality {
+		centrality[id] /= 2
+		if n > 2 {
+			centrality[id] /= (n - 1) * (n - 2) / 2
+		}
+	}
+	return centrality
+}
+
+// cycles returns directed cycles found via back edges during a depth-first search.
+func (g *conceptGraph) cycles(limit int) [][]uint {
+	const (
+		white = iota
+		grey
+		black
+	)
+	colour := make(map[uint]int)
+	var path []uint
+	var cycles [][]uint
+
+	var visit func(v uint)
+	visit = func(v uint) {
+		colour[v] = grey
+		path = append(path, v)
+		for _, e := range g.Out[v] {
+			if len(cycles) >= limit {
+				break
+			}
+			switch colour[e.To] {
+			case white:
+				visit(e.To)
+			case grey:
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == e.To {
+						cycle := append([]uint(nil), path[i:]...)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		colour[v] = black
+	}
+
+	for _, v := range g.Nodes {
+		if len(cycles) >= limit {
+			break
+		}
+		if colour[v] == white {
+			visit(v)
+		}
+	}
+	return cycles
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/analysis
+func (db *DBHandler) GetMindMapAnalysis(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var layouts []models.MindMapNodeLayout
+	if err := db.Where("mind_map_id = ?", mindMap.ID).Find(&layouts).Error; err != nil {
+		http.Error(w, "Failed to fetch node layouts", http.StatusInternalServerError)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+	}
+
+	ref := func(id uint) cardRef {
+		return newCardRef(id, cardsByID)
+	}
+
+	type NodeStats struct {
+		cardRef
+		InDegree    int
+		OutDegree   int
+		Degree      int
+		Betweenness float64
+	}
+	type Component struct {
+		Size  int
+		Cards []cardRef
+	}
+	type AnalysisResponse struct {
+		MindMapID  string
+		NodeCount  int
+		EdgeCount  int
+		Nodes      []NodeStats
+		Components []Component
+		Orphans    []cardRef
+		Cycles     [][]cardRef
+		// Edge counts by relationship type key, with untyped edges under "untyped"
+		RelationshipTypes map[string]int
+	}
+
+	graph := newConceptGraph(mindMap.Connections, layouts)
+	centrality := graph.betweenness()
+
+	response := AnalysisResponse{
+		MindMapID:  mindMap.PublicID,
+		NodeCount:  len(graph.Nodes),
+		Nodes:      []NodeStats{},
+		Components: []Component{},
+		Orphans:    []cardRef{},
+		Cycles:     [][]cardRef{},
+
+		RelationshipTypes: map[string]int{},
+	}
+	for _, conn := range mindMap.Connections {
+		key := conn.RelationshipType
+		if key == "" {
+			key = untypedRelationship
+		}
+		response.RelationshipTypes[key]++
+	}
+	for _, id := range graph.Nodes {
+		in, out := len(graph.In[id]), len(graph.Out[id])
+		response.EdgeCount += out
+		response.Nodes = append(response.Nodes, NodeStats{
+			cardRef:     ref(id),
+			InDegree:    in,
+			OutDegree:   out,
+			Degree:      in + out,
+			Betweenness: centrality[id],
+		})
+	}
+	sort.SliceStable(response.Nodes, func(i, j int) bool {
+		if response.Nodes[i].Betweenness != response.Nodes[j].Betweenness {
+			return response.Nodes[i].Betweenness > response.Nodes[j].Betweenness
+		}
+		return response.Nodes[i].Degree > response.Nodes[j].Degree
+	})
+
+	for _, component := range graph.components() {
+		refs := make([]cardRef, 0, len(component))
+		for _, id := range component {
+			refs = append(refs, ref(id))
+		}
+		response.Components = append(response.Components, Component{Size: len(refs), Cards: refs})
+	}
+
+	// Orphans are cards that aren't on the map, so only callers who can read
+	// the whole set see them
+	readsSet := set.IsPublic || db.hasSetRole(r, &set, models.OrgViewer) || db.sharedWith(r, set.ID, 0, models.ShareScopeView)
+	if readsSet {
+		covered := make(map[uint]bool, len(graph.Nodes))
+		for _, id := range graph.Nodes {
+			covered[id] = true
+		}
+		for _, fc := range flashcards {
+			if !covered[fc.ID] {
+				response.Orphans = append(response.Orphans, ref(fc.ID))
+			}
+		}
+	}
+
+	for _, cycle := range graph.cycles(maxReportedCycles) {
+		refs := make([]cardRef, 0, len(cycle))
+		for _, id := range cycle {
+			refs = append(refs, ref(id))
+		}
+		response.Cycles = append(response.Cycles, refs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	eventTypeOps      = "ops"
+	eventTypePresence = "presence"
+	eventTypeLeave    = "leave"
+
+	subscriberBuffer  = 64
+	heartbeatInterval = 25 * time.Second
+)
+
+type mindMapPresence struct {
+	ClientID     string  `json:"clientID"`
+	Nickname     string  `json:"nickname"`
+	X            float64 `json:"x"`
+	Y            float64 `json:"y"`
+	SelectedNode uint    `json:"selectedNode,omitempty"`
+
+	userID uint // Who the clientID belongs to; 0 for anonymous viewers
+}
+
+type mindMapEvent struct {
+	Type     string           `json:"type"`
+	Seq      uint             `json:"seq,omitempty"`
+	Ops      []mindMapOp      `json:"ops,omitempty"`
+	Presence *mindMapPresence `json:"presence,omitempty"`
+}
+
+type mindMapSubscriber struct {
+	userID   uint
+	clientID string
+	events   chan mindMapEvent
+}
+
+// presenceKey identifies a cursor. Client IDs are chosen by clients, so they
+// only count together with the user who opened the stream.
+type presenceKey struct {
+	userID   uint
+	clientID string
+}
+
+var (
+	errClientIDTaken = errors.New("clientID is in use by another user")
+	errNoStream      = errors.New("no open event stream for clientID")
+)
+
+// mindMapHub fans out applied operations and cursor presence to every client
+// watching a mind map. Ordering comes from the Seq assigned in the database,
+// so the hub only has to deliver events, never resolve them.
+type mindMapHub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[*mindMapSubscriber]struct{}
+	presence    map[uint]map[presenceKey]mindMapPresence
+}
+
+var mindMapEvents = &mindMapHub{
+	subscribers: make(map[uint]map[*mindMapSubscriber]struct{}),
+	presence:    make(map[uint]map[presenceKey]mindMapPresence),
+}
+
+// subscribe opens a stream for userID. While it's open no other user can
+// subscribe or post presence under the same clientID.
+func (h *mindMapHub) subscribe(mindMapID, userID uint, clientID string) (*mindMapSubscriber, []mindMapPresence, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if clientID != "" {
+		for other := range h.subscribers[mindMapID] {
+			if other.clientID == clientID && other.userID != userID {
+				return nil, nil, errClientIDTaken
+			}
+		}
+	}
+	sub := &mindMapSubscriber{userID: userID, clientID: clientID, events: make(chan mindMapEvent, subscriberBuffer)}
+	if h.subscribers[mindMapID] == nil {
+		h.subscribers[mindMapID] = make(map[*mindMapSubscriber]struct{})
+	}
+	h.subscribers[mindMapID][sub] = struct{}{}
+
+	var present []mindMapPresence
+	for key, p := range h.presence[mindMapID] {
+		if key != (presenceKey{userID, clientID}) {
+			present = append(present, p)
+		}
+	}
+	return sub, present, nil
+}
+
+func (h *mindMapHub) unsubscribe(mindMapID uint, sub *mindMapSubscriber) {
+	h.mu.Lock()
+	_, subscribed := h.subscribers[mindMapID][sub]
+	delete(h.subscribers[mindMapID], sub)
+	if len(h.subscribers[mindMapID]) == 0 {
+		delete(h.subscribers, mindMapID)
+	}
+	// The cursor stays while the same user has another stream open under it
+	key := presenceKey{sub.userID, sub.clientID}
+	shared := false
+	for other := range h.subscribers[mindMapID] {
+		if other.userID == sub.userID && other.clientID == sub.clientID {
+			shared = true
+			break
+		}
+	}
+	_, hadPresence := h.presence[mindMapID][key]
+	if sub.clientID != "" && !shared {
+		delete(h.presence[mindMapID], key)
+	} else {
+		hadPresence = false
+	}
+	h.mu.Unlock()
+
+	if subscribed {
+		close(sub.events)
+	}
+	if hadPresence {
+		h.publish(mindMapID, mindMapEvent{Type: eventTypeLeave, Presence: &mindMapPresence{ClientID: sub.clientID, userID: sub.userID}})
+	}
+}
+
+// publish delivers event to every subscriber of the mind map. A subscriber that
+// has fallen too far behind is dropped and expected to reconnect with since=.
+func (h *mindMapHub) publish(mindMapID uint, event mindMapEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers[mindMapID] {
+		if event.Presence != nil && event.Type == eventTypePresence &&
+			sub.clientID == event.Presence.ClientID && sub.userID == event.Presence.userID {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers[mindMapID], sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (h *mindMapHub) publishOps(mindMapID uint, ops []mindMapOp) {
+	if len(ops) == 0 {
+		return
+	}
+	h.publish(mindMapID, mindMapEvent{Type: eventTypeOps, Seq: ops[len(ops)-1].Seq, Ops: ops})
+}
+
+// updatePresence records and broadcasts a cursor. It's refused unless the
+// same user has a stream open under presence.ClientID, which also clears the
+// cursor when it disconnects.
+func (h *mindMapHub) updatePresence(mindMapID uint, presence mindMapPresence) error {
+	h.mu.Lock()
+	found := false
+	for sub := range h.subscribers[mindMapID] {
+		if sub.clientID == presence.ClientID && sub.userID == presence.userID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		h.mu.Unlock()
+		return errNoStream
+	}
+	if h.presence[mindMapID] == nil {
+		h.presence[mindMapID] = make(map[presenceKey]mindMapPresence)
+	}
+	h.presence[mindMapID][presenceKey{presence.userID, presence.ClientID}] = presence
+	h.mu.Unlock()
+	h.publish(mindMapID, mindMapEvent{Type: eventTypePresence, Presence: &presence})
+	return nil
+}
+
+func writeEvent(w http.ResponseWriter, event mindMapEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.Seq != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.Seq); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/events?since=&clientID=
+func (db *DBHandler) StreamMindMapEvents(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	// EventSource resends the last seen id on reconnect
+	since := r.Header.Get("Last-Event-ID")
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		since = raw
+	}
+	var sinceSeq uint64
+	if since != "" {
+		parsed, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			http.Error(w, "since must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		sinceSeq = parsed
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Anonymous viewers of public maps subscribe as user 0
+	var userID uint
+	if auth0ID, ok := utils.GetAuth0ID(r); ok {
+		var user models.User
+		if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err == nil {
+			userID = user.ID
+		}
+	}
+
+	// Subscribe before replaying so nothing committed in between is missed;
+	// clients drop any op whose seq they have already applied.
+	sub, present, err := mindMapEvents.subscribe(mindMap.ID, userID, r.URL.Query().Get("clientID"))
+	if err != nil {
+		http.Error(w, "That clientID is in use by another user", http.StatusConflict)
+		return
+	}
+	defer mindMapEvents.unsubscribe(mindMap.ID, sub)
+
+	var records []models.MindMapOperation
+	if err := db.Where("mind_map_id = ? AND seq > ?", mindMap.ID, sinceSeq).Order("seq asc").Find(&records).Error; err != nil {
+		http.Error(w, "Failed to fetch operations", http.StatusInternalServerError)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if len(records) > 0 {
+		ops := make([]mindMapOp, 0, len(records))
+		for _, record := range records {
+			ops = append(ops, opFromModel(record))
+		}
+		if err := writeEvent(w, mindMapEvent{Type: eventTypeOps, Seq: ops[len(ops)-1].Seq, Ops: ops}); err != nil {
+			return
+		}
+	}
+	for i := range present {
+		if err := writeEvent(w, mindMapEvent{Type: eventTypePresence, Presence: &present[i]}); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-sub.events:
+			if !open {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/presence
+func (db *DBHandler) UpdateMindMapPresence(w http.ResponseWriter, r *http.Request) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	// Anyone who can open the stream can share their cursor on it
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var presence mindMapPresence
+	if err := json.NewDecoder(r.Body).Decode(&presence); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if presence.ClientID == "" {
+		http.Error(w, "clientID is required", http.StatusBadRequest)
+		return
+	}
+	presence.Nickname = user.Nickname
+	presence.userID = user.ID
+
+	if err := mindMapEvents.updatePresence(mindMap.ID, presence); err != nil {
+		http.Error(w, "Open the event stream with this clientID first", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	defaultPathPrompts   = 5
+	maxPathPrompts       = 20
+	maxExplanationLength = 5000
+	minMentionTermLength = 3
+
+	// minPromptSteps is the shortest chain worth explaining; single
+	// connections are only used when a map has nothing longer
+	minPromptSteps = 2
+)
+
+// pathPrompt asks a learner to explain how one card leads to another. The
+// paths themselves are held back until an explanation is submitted.
+type pathPrompt struct {
+	From   cardRef
+	To     cardRef
+	Prompt string
+	Steps  int // Length of the shortest path
+	Paths  int // Distinct paths found, up to defaultPathCount
+}
+
+type explanationResponse struct {
+	PublicID     string
+	From         cardRef
+	To           cardRef
+	Explanation  string
+	StepsCovered int
+	TotalSteps   int
+	CreatedAt    time.Time
+}
+
+func newExplanationResponse(e models.MindMapPathExplanation, cardsByID map[uint]models.Flashcard) explanationResponse {
+	return explanationResponse{
+		PublicID:     e.PublicID,
+		From:         newCardRef(e.FromFlashcardID, cardsByID),
+		To:           newCardRef(e.ToFlashcardID, cardsByID),
+		Explanation:  e.Explanation,
+		StepsCovered: e.StepsCovered,
+		TotalSteps:   e.TotalSteps,
+		CreatedAt:    e.CreatedAt,
+	}
+}
+
+// distancesFrom returns the number of connections on the shortest path from
+// one card to every card it reaches.
+func (g *conceptGraph) distancesFrom(from uint) map[uint]int {
+	distances := map[uint]int{from: 0}
+	queue := []uint{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Out[current] {
+			if _, seen := distances[e.To]; !seen {
+				distances[e.To] = distances[current] + 1
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	return distances
+}
+
+// termPattern matches term as whole words, ignoring case. It returns nil for
+// terms too short to count as a mention. Word boundaries only go on ends that
+// are word characters, since "C++" or "pH (acid)" could never match otherwise.
+func termPattern(term string) *regexp.Regexp {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if len(term) < minMentionTermLength {
+		return nil
+	}
+	expr := regexp.QuoteMeta(term)
+	if isWordByte(term[0]) {
+		expr = `\b` + expr
+	}
+	if isWordByte(term[len(term)-1]) {
+		expr += `\b`
+	}
+	pattern, err := regexp.Compile(`(?i)` + expr)
+	if err != nil {
+		return nil
+	}
+	return pattern
+}
+
+// isWordByte matches the ASCII word characters regexp's \b is defined by.
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// explainedSteps reports, for each step of path, whether the explanation
+// names its relationship or the card it leads to.
+func explainedSteps(explanation string, path conceptPath, cardsByID map[uint]models.Flashcard) []bool {
+	named := make([]bool, len(path))
+	for i, conn := range path {
+		for _, term := range []string{conn.Relationship, cardsByID[conn.TargetID].Term} {
+			if pattern := termPattern(term); pattern != nil && pattern.MatchString(explanation) {
+				named[i] = true
+			}
+		}
+	}
+	return named
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/path/prompts?count=
+//
+// Picks pairs of cards joined by a chain of connections and asks the learner
+// to explain how the first leads to the second.
+func (db *DBHandler) GetMindMapPathPrompts(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	count := defaultPathPrompts
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		count = min(parsed, maxPathPrompts)
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeStudy) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+	}
+
+	// Longer chains make better prompts, so they're drawn first
+	graph := newConceptGraph(mindMap.Connections, nil)
+	var chains, direct [][2]uint
+	for _, from := range graph.Nodes {
+		for to, distance := range graph.distancesFrom(from) {
+			switch {
+			case distance >= minPromptSteps:
+				chains = append(chains, [2]uint{from, to})
+			case distance == 1:
+				direct = append(direct, [2]uint{from, to})
+			}
+		}
+	}
+	rand.Shuffle(len(chains), func(i, j int) { chains[i], chains[j] = chains[j], chains[i] })
+	rand.Shuffle(len(direct), func(i, j int) { direct[i], direct[j] = direct[j], direct[i] })
+
+	prompts := []pathPrompt{}
+	for _, pair := range append(chains, direct...) {
+		if len(prompts) == count {
+			break
+		}
+		paths := graph.kShortestPaths(pair[0], pair[1], defaultPathCount)
+		if len(paths) == 0 {
+			continue
+		}
+		from, to := newCardRef(pair[0], cardsByID), newCardRef(pair[1], cardsByID)
+		prompts = append(prompts, pathPrompt{
+			From:   from,
+			To:     to,
+			Prompt: fmt.Sprintf("Explain how %s leads to %s", from.Term, to.Term),
+			Steps:  len(paths[0]),
+			Paths:  len(paths),
+		})
+	}
+
+	type PromptResponse struct {
+		MindMapID string
+		Prompts   []pathPrompt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PromptResponse{
+		MindMapID: mindMap.PublicID,
+		Prompts:   prompts,
+	})
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/path/explanations
+//
+// Records a learner's explanation of how one card leads to another and shows
+// them the paths drawn in the map, marking the steps their explanation named.
+func (db *DBHandler) CreateMindMapPathExplanation(w http.ResponseWriter, r *http.Request) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		FromID      string
+		ToID        string
+		Explanation string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Explanation = strings.TrimSpace(req.Explanation)
+	if req.FromID == "" || req.ToID == "" || req.Explanation == "" {
+		http.Error(w, "FromID, ToID and Explanation are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Explanation) > maxExplanationLength {
+		http.Error(w, "Explanation must be at most 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.hasSetRole(r, &set, models.OrgViewer) && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeStudy) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found in database", http.StatusNotFound)
+		return
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	var from, to uint
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+		if fc.PublicID == req.FromID {
+			from = fc.ID
+		}
+		if fc.PublicID == req.ToID {
+			to = fc.ID
+		}
+	}
+	if from == 0 || to == 0 {
+		http.Error(w, "Flashcard not found in set", http.StatusNotFound)
+		return
+	}
+	if from == to {
+		http.Error(w, "FromID and ToID must be different flashcards", http.StatusBadRequest)
+		return
+	}
+
+	paths := newConceptGraph(mindMap.Connections, nil).kShortestPaths(from, to, defaultPathCount)
+	if len(paths) == 0 {
+		http.Error(w, "The mind map has no path between these flashcards", http.StatusBadRequest)
+		return
+	}
+
+	type ExplainedStep struct {
+		From         cardRef
+		To           cardRef
+		Relationship string
+		Named        bool
+	}
+	type ExplainedPath struct {
+		Length       int
+		StepsCovered int
+		Steps        []ExplainedStep
+	}
+	type ExplainResponse struct {
+		Explanation explanationResponse
+		Paths       []ExplainedPath
+	}
+	response := ExplainResponse{Paths: []ExplainedPath{}}
+
+	// The explanation is scored against whichever path it follows most closely
+	best := -1
+	for i, p := range paths {
+		named := explainedSteps(req.Explanation, p, cardsByID)
+		path := ExplainedPath{Length: len(p)}
+		for j, conn := range p {
+			if named[j] {
+				path.StepsCovered++
+			}
+			path.Steps = append(path.Steps, ExplainedStep{
+				From:         newCardRef(conn.SourceID, cardsByID),
+				To:           newCardRef(conn.TargetID, cardsByID),
+				Relationship: conn.Relationship,
+				Named:        named[j],
+			})
+		}
+		if best < 0 || path.StepsCovered*response.Paths[best].Length > response.Paths[best].StepsCovered*path.Length {
+			best = i
+		}
+		response.Paths = append(response.Paths, path)
+	}
+
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	explanation := models.MindMapPathExplanation{
+		PublicID:        publicID,
+		UserID:          user.ID,
+		MindMapID:       mindMap.ID,
+		FromFlashcardID: from,
+		ToFlashcardID:   to,
+		Explanation:     req.Explanation,
+		StepsCovered:    response.Paths[best].StepsCovered,
+		TotalSteps:      response.Paths[best].Length,
+	}
+	if err := db.Omit("User", "MindMap", "FromFlashcard", "ToFlashcard").Create(&explanation).Error; err != nil {
+		http.Error(w, "Failed to record explanation", http.StatusInternalServerError)
+		return
+	}
+	response.Explanation = newExplanationResponse(explanation, cardsByID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/path/explanations
+func (db *DBHandler) GetMindMapPathExplanations(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	mindMap, ok := db.loadReadableMindMap(w, r)
+	if !ok {
+		return
+	}
+
+	var explanations []models.MindMapPathExplanation
+	if err := db.Where("mind_map_id = ? AND user_id = ?", mindMap.ID, user.ID).Order("created_at desc").Find(&explanations).Error; err != nil {
+		http.Error(w, "Failed to fetch explanations", http.StatusInternalServerError)
+		return
+	}
+	// Cards deleted since keep their terms in the history
+	var flashcards []models.Flashcard
+	if err := db.Unscoped().Where("set_id = ?", mindMap.SetID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+	}
+
+	response := make([]explanationResponse, 0, len(explanations))
+	for _, e := range explanations {
+		response = append(response, newExplanationResponse(e, cardsByID))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
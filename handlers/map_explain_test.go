@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+func TestExplainedSteps(t *testing.T) {
+	connections := testConnections([][2]uint{{1, 2}, {2, 3}})
+	connections[0].Relationship = "causes"
+	connections[1].Relationship = "leads to"
+	graph := newConceptGraph(connections, nil)
+	path := graph.shortestPath(1, 3, nil, nil)
+	cardsByID := map[uint]models.Flashcard{
+		1: {Term: "Rain"},
+		2: {Term: "Flooding"},
+		3: {Term: "Erosion"},
+	}
+
+	tests := []struct {
+		name        string
+		explanation string
+		want        []bool
+	}{
+		{"names nothing", "it just happens", []bool{false, false}},
+		{"names a card", "There is flooding, then more", []bool{true, false}},
+		{"names a relationship", "Rain causes it", []bool{true, false}},
+		{"names everything", "Rain causes flooding which leads to erosion", []bool{true, true}},
+		{"partial words don't count", "floodingly erosions", []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainedSteps(tt.explanation, path, cardsByID); !slices.Equal(got, tt.want) {
+				t.Errorf("explainedSteps(%q) = %v, want %v", tt.explanation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermPattern(t *testing.T) {
+	tests := []struct {
+		term string
+		text string
+		want bool
+	}{
+		{"Rain", "the rain falls", true},
+		{"Rain", "it was raining", false},
+		{"Rain", "terrain", false},
+		{"C++", "written in c++ mostly", true},
+		{"C++", "written in C++.", true},
+		{"C++", "abc++", false},
+		{"pH (acid)", "measure the pH (acid) level", true},
+		{"pH (acid)", "graph (acid)", false},
+		{".NET", "built on .NET today", true},
+		{"café", "a café nearby", true},
+		{"  DNA  ", "dna replication", true},
+		{"ab", "ab", false},
+	}
+	for _, tt := range tests {
+		pattern := termPattern(tt.term)
+		got := pattern != nil && pattern.MatchString(tt.text)
+		if got != tt.want {
+			t.Errorf("termPattern(%q) matching %q = %v, want %v", tt.term, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDistancesFrom(t *testing.T) {
+	graph := newConceptGraph(testConnections([][2]uint{{1, 2}, {2, 3}, {1, 3}, {3, 4}, {5, 1}}), nil)
+	got := graph.distancesFrom(1)
+	want := map[uint]int{1: 0, 2: 1, 3: 1, 4: 2}
+	if len(got) != len(want) {
+		t.Fatalf("distancesFrom(1) = %v, want %v", got, want)
+	}
+	for id, distance := range want {
+		if got[id] != distance {
+			t.Errorf("distancesFrom(1)[%d] = %d, want %d", id, got[id], distance)
+		}
+	}
+}
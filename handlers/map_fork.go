@@ -0,0 +1,598 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	templateNodeSpacingX = 260.0
+	templateNodeSpacingY = 160.0
+	hierarchyBranching   = 3
+)
+
+// mindMapTemplate lays out a set's flashcards into a ready-made structure.
+type mindMapTemplate struct {
+	ID           string
+	Name         string
+	Description  string
+	Relationship string
+	// build returns the index pairs to connect and a position for every card
+	build func(n int) (edges [][2]int, positions [][2]float64)
+}
+
+var mindMapTemplates = []mindMapTemplate{
+	{
+		ID:           "timeline",
+		Name:         "Timeline",
+		Description:  "Cards in set order along a single horizontal line",
+		Relationship: "then",
+		build: func(n int) ([][2]int, [][2]float64) {
+			var edges [][2]int
+			positions := make([][2]float64, n)
+			for i := 0; i < n; i++ {
+				positions[i] = [2]float64{float64(i) * templateNodeSpacingX, 0}
+				if i > 0 {
+					edges = append(edges, [2]int{i - 1, i})
+				}
+			}
+			return edges, positions
+		},
+	},
+	{
+		ID:           "cause-effect",
+		Name:         "Cause and effect",
+		Description:  "A chain where each card causes the next, staggered to leave room for labels",
+		Relationship: "causes",
+		build: func(n int) ([][2]int, [][2]float64) {
+			var edges [][2]int
+			positions := make([][2]float64, n)
+			for i := 0; i < n; i++ {
+				positions[i] = [2]float64{float64(i) * templateNodeSpacingX, float64(i%2) * templateNodeSpacingY}
+				if i > 0 {
+					edges = append(edges, [2]int{i - 1, i})
+				}
+			}
+			return edges, positions
+		},
+	},
+	{
+		ID:           "hierarchy",
+		Name:         "Hierarchy",
+		Description:  "The first card as the root with the rest arranged as a tree beneath it",
+		Relationship: "includes",
+		build: func(n int) ([][2]int, [][2]float64) {
+			var edges [][2]int
+			positions := make([][2]float64, n)
+			levelStart, levelSize, depth := 0, 1, 0
+			for levelStart < n {
+				levelEnd := min(levelStart+levelSize, n)
+				width := float64(levelEnd-levelStart-1) * templateNodeSpacingX
+				for i := levelStart; i < levelEnd; i++ {
+					x := float64(i-levelStart)*templateNodeSpacingX - width/2
+					positions[i] = [2]float64{x, float64(depth) * templateNodeSpacingY}
+					if i > 0 {
+						edges = append(edges, [2]int{(i - 1) / hierarchyBranching, i})
+					}
+				}
+				levelStart = levelEnd
+				levelSize *= hierarchyBranching
+				depth++
+			}
+			return edges, positions
+		},
+	},
+}
+
+func findMindMapTemplate(id string) (mindMapTemplate, bool) {
+	for _, t := range mindMapTemplates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return mindMapTemplate{}, false
+}
+
+// layoutData builds the node Data payload the frontend renders as a label.
+func layoutData(term string) string {
+	const maxData = 200
+	label := []rune(term)
+	for {
+		data, _ := json.Marshal(map[string]string{"label": string(label)})
+		if len(data) <= maxData || len(label) == 0 {
+			return string(data)
+		}
+		label = label[:len(label)-1]
+	}
+}
+
+func normalizeTerm(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
+
+// createMindMapGraph creates a mind map with the given nodes, connections and
+// layouts in one transaction.
+func createMindMapGraph(db *gorm.DB, mindMap *models.MindMap, nodes []models.MindMapNode, connections []models.MindMapConnection, layouts []models.MindMapNodeLayout) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(mindMap).Error; err != nil {
+			return err
+		}
+		return insertMindMapGraph(tx, mindMap, nodes, connections, layouts)
+	})
+}
+
+// insertMindMapGraph adds nodes, connections and layouts to an existing mind
+// map. Rows may carry the IDs they had where they were copied from; nodes get
+// new IDs and references to them from connections, layouts and parent groups
+// are rewritten to match.
+func insertMindMapGraph(tx *gorm.DB, mindMap *models.MindMap, nodes []models.MindMapNode, connections []models.MindMapConnection, layouts []models.MindMapNodeLayout) error {
+	nodeIDs := make(map[uint]uint, len(nodes))
+	for i := range nodes {
+		oldID := nodes[i].ID
+		publicID, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		nodes[i].Model = gorm.Model{}
+		nodes[i].MindMapID = mindMap.ID
+		nodes[i].PublicID = publicID
+		parentID := nodes[i].ParentID
+		nodes[i].ParentID = nil
+		if err := tx.Omit(clause.Associations).Create(&nodes[i]).Error; err != nil {
+			return err
+		}
+		nodes[i].ParentID = parentID
+		nodeIDs[oldID] = nodes[i].ID
+	}
+	remapNode := func(id *uint) *uint {
+		if id == nil {
+			return nil
+		}
+		if newID, ok := nodeIDs[*id]; ok {
+			return &newID
+		}
+		return nil
+	}
+	for i := range nodes {
+		if nodes[i].ParentID == nil {
+			continue
+		}
+		nodes[i].ParentID = remapNode(nodes[i].ParentID)
+		if err := tx.Model(&nodes[i]).Update("parent_id", nodes[i].ParentID).Error; err != nil {
+			return err
+		}
+	}
+
+	vocabulary, err := relationshipVocabulary(tx, mindMap.SetID)
+	if err != nil {
+		return err
+	}
+	for i := range connections {
+		connections[i].Model = gorm.Model{}
+		connections[i].MindMapID = mindMap.ID
+		classifyConnection(vocabulary, &connections[i])
+		connections[i].SourceNodeID = remapNode(connections[i].SourceNodeID)
+		connections[i].TargetNodeID = remapNode(connections[i].TargetNodeID)
+	}
+	for i := range layouts {
+		layouts[i].Model = gorm.Model{}
+		layouts[i].MindMapID = mindMap.ID
+		layouts[i].GroupID = remapNode(layouts[i].GroupID)
+	}
+	if len(connections) > 0 {
+		if err := tx.Omit(clause.Associations).Create(&connections).Error; err != nil {
+			return err
+		}
+	}
+	if len(layouts) > 0 {
+		if err := tx.Create(&layouts).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forkedSet returns the new private set, owned by userID, that forking source
+// creates. A private organization set is forked within its organization so its
+// content doesn't leave it. Nothing is saved, so permissions on the fork can be
+// checked before anything is written.
+func forkedSet(source models.FlashcardSet, userID uint) (models.FlashcardSet, error) {
+	publicID, err := gonanoid.New()
+	if err != nil {
+		return models.FlashcardSet{}, err
+	}
+	fork := models.FlashcardSet{
+		Title:    source.Title,
+		UserID:   userID,
+		PublicID: publicID,
+	}
+	if !source.IsPublic {
+		fork.OrganizationID = source.OrganizationID
+	}
+	return fork, nil
+}
+
+// forkSet saves fork, built by forkedSet, and copies source's sections and
+// flashcards into it.
+func forkSet(tx *gorm.DB, source models.FlashcardSet, fork *models.FlashcardSet) error {
+	var flashcards []models.Flashcard
+	if err := tx.Where("set_id = ?", source.ID).Find(&flashcards).Error; err != nil {
+		return err
+	}
+	var sections []models.Section
+	if err := tx.Where("set_id = ?", source.ID).Find(&sections).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(fork).Error; err != nil {
+		return err
+	}
+	sectionIDs := make(map[uint]uint, len(sections))
+	for _, section := range sections {
+		sectionID, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		copied := models.Section{
+			SetID:    fork.ID,
+			PublicID: sectionID,
+			Title:    section.Title,
+			Position: section.Position,
+		}
+		if err := tx.Omit("FlashcardSet").Create(&copied).Error; err != nil {
+			return err
+		}
+		sectionIDs[section.ID] = copied.ID
+	}
+	for _, fc := range flashcards {
+		cardID, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		copied := models.Flashcard{
+			Term:     fc.Term,
+			Solution: fc.Solution,
+			Concept:  fc.Concept,
+			Format:   fc.Format,
+			Language: fc.Language,
+			Type:     fc.Type,
+			PublicID: cardID,
+			SetID:    fork.ID,
+			Position: fc.Position,
+		}
+		if fc.SectionID != nil {
+			if id, ok := sectionIDs[*fc.SectionID]; ok {
+				copied.SectionID = &id
+			}
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/fork
+func (db *DBHandler) ForkMindMap(w http.ResponseWriter, r *http.Request) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		DestinationSetID string `json:"DestinationSetID,omitempty"`
+		ForkSet          bool   `json:"ForkSet"`
+		Title            string `json:"Title,omitempty"`
+		IsPublic         bool   `json:"IsPublic"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	var sourceSet models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&sourceSet).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var sourceMap models.MindMap
+	if err := db.Preload("Connections").Preload("Nodes").Where("public_id = ? AND set_id = ?", mindMapID, sourceSet.ID).First(&sourceMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !sourceMap.IsPublic && !db.hasSetRole(r, &sourceSet, models.OrgViewer) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var destSet models.FlashcardSet
+	switch {
+	case req.ForkSet:
+		if !sourceSet.IsPublic && !db.hasSetRole(r, &sourceSet, models.OrgViewer) {
+			http.Error(w, "Forbidden: set is not public", http.StatusForbidden)
+			return
+		}
+		fork, err := forkedSet(sourceSet, user.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+			return
+		}
+		destSet = fork
+	case req.DestinationSetID != "":
+		if err := db.Where("public_id = ?", req.DestinationSetID).First(&destSet).Error; err != nil {
+			http.Error(w, "Destination set not found", http.StatusNotFound)
+			return
+		}
+	default:
+		destSet = sourceSet
+	}
+	if !db.hasSetRole(r, &destSet, models.OrgEditor) {
+		http.Error(w, "Forbidden: you can't edit the destination set", http.StatusForbidden)
+		return
+	}
+	// Private organization content may only be copied within its organization
+	if !sourceMap.IsPublic && sourceSet.OrganizationID != nil && !sameTenant(&sourceSet, &destSet) {
+		http.Error(w, "Forbidden: the destination set is outside the source's organization", http.StatusForbidden)
+		return
+	}
+
+	var sourceCards []models.Flashcard
+	if err := db.Where("set_id = ?", sourceSet.ID).Find(&sourceCards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	var sourceLayouts []models.MindMapNodeLayout
+	if err := db.Where("mind_map_id = ?", sourceMap.ID).Find(&sourceLayouts).Error; err != nil {
+		http.Error(w, "Failed to fetch node layouts", http.StatusInternalServerError)
+		return
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	title := req.Title
+	if title == "" {
+		title = sourceMap.Title
+	}
+
+	// The forked set and the mind map are created together, so a failure
+	// leaves neither behind
+	var connections []models.MindMapConnection
+	var layouts []models.MindMapNodeLayout
+	var unmatched []string
+	droppedConnections := 0
+	var mindMap models.MindMap
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if req.ForkSet {
+			if err := forkSet(tx, sourceSet, &destSet); err != nil {
+				return err
+			}
+		}
+		var destCards []models.Flashcard
+		if err := tx.Where("set_id = ?", destSet.ID).Find(&destCards).Error; err != nil {
+			return err
+		}
+
+		// Map each source card onto the first destination card with the same term
+		destByTerm := make(map[string]uint, len(destCards))
+		for _, fc := range destCards {
+			key := normalizeTerm(fc.Term)
+			if _, exists := destByTerm[key]; !exists {
+				destByTerm[key] = fc.ID
+			}
+		}
+		// Only cards the map uses are remapped, so the rest of a private
+		// source set never shows up in UnmatchedTerms
+		used := make(map[uint]bool)
+		for _, conn := range sourceMap.Connections {
+			if conn.SourceNodeID == nil {
+				used[conn.SourceID] = true
+			}
+			if conn.TargetNodeID == nil {
+				used[conn.TargetID] = true
+			}
+		}
+		for _, layout := range sourceLayouts {
+			used[layout.FlashcardID] = true
+		}
+		remap := make(map[uint]uint, len(sourceCards))
+		for _, fc := range sourceCards {
+			if !used[fc.ID] {
+				continue
+			}
+			if id, ok := destByTerm[normalizeTerm(fc.Term)]; ok {
+				remap[fc.ID] = id
+			} else {
+				unmatched = append(unmatched, fc.Term)
+			}
+		}
+
+		// Node endpoints are copied with the map and remapped by createMindMapGraph
+		remapEnd := func(flashcardID uint, nodeID *uint) (uint, bool) {
+			if nodeID != nil {
+				return 0, true
+			}
+			id, ok := remap[flashcardID]
+			return id, ok
+		}
+		for _, conn := range sourceMap.Connections {
+			source, sourceOK := remapEnd(conn.SourceID, conn.SourceNodeID)
+			target, targetOK := remapEnd(conn.TargetID, conn.TargetNodeID)
+			if !sourceOK || !targetOK {
+				droppedConnections++
+				continue
+			}
+			connections = append(connections, models.MindMapConnection{
+				SourceID:         source,
+				TargetID:         target,
+				SourceNodeID:     conn.SourceNodeID,
+				TargetNodeID:     conn.TargetNodeID,
+				Relationship:     conn.Relationship,
+				RelationshipType: conn.RelationshipType,
+				Note:             conn.Note,
+			})
+		}
+		for _, layout := range sourceLayouts {
+			id, ok := remap[layout.FlashcardID]
+			if !ok {
+				continue
+			}
+			layouts = append(layouts, models.MindMapNodeLayout{
+				FlashcardID: id,
+				XPosition:   layout.XPosition,
+				YPosition:   layout.YPosition,
+				Data:        layout.Data,
+				GroupID:     layout.GroupID,
+			})
+		}
+
+		mindMap = models.MindMap{
+			Title:    title,
+			SetID:    destSet.ID,
+			UserID:   user.ID,
+			IsPublic: req.IsPublic,
+			PublicID: publicID,
+
+			OrganizationID: destSet.OrganizationID,
+		}
+		return createMindMapGraph(tx, &mindMap, sourceMap.Nodes, connections, layouts)
+	})
+	if err != nil {
+		http.Error(w, "Failed to fork mind map", http.StatusInternalServerError)
+		return
+	}
+
+	type ForkResponse struct {
+		SetID              string
+		MindMap            models.MindMap
+		NodeLayouts        []models.MindMapNodeLayout `json:"nodeLayouts"`
+		UnmatchedTerms     []string
+		DroppedConnections int
+	}
+	mindMap.Connections = connections
+	mindMap.Nodes = sourceMap.Nodes
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ForkResponse{
+		SetID:              destSet.PublicID,
+		MindMap:            mindMap,
+		NodeLayouts:        layouts,
+		UnmatchedTerms:     unmatched,
+		DroppedConnections: droppedConnections,
+	})
+}
+
+// GET /api/mindmaps/templates
+func (db *DBHandler) GetMindMapTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mindMapTemplates)
+}
+
+// POST /api/sets/{setID}/mindmaps/templates
+func (db *DBHandler) CreateMindMapFromTemplate(w http.ResponseWriter, r *http.Request) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	var req struct {
+		TemplateID string `json:"TemplateID"`
+		Title      string `json:"Title,omitempty"`
+		IsPublic   bool   `json:"IsPublic"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	template, found := findMindMapTemplate(req.TemplateID)
+	if !found {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID)).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	edges, positions := template.build(len(flashcards))
+	connections := make([]models.MindMapConnection, 0, len(edges))
+	for _, edge := range edges {
+		connections = append(connections, models.MindMapConnection{
+			SourceID:     flashcards[edge[0]].ID,
+			TargetID:     flashcards[edge[1]].ID,
+			Relationship: template.Relationship,
+		})
+	}
+	layouts := make([]models.MindMapNodeLayout, 0, len(flashcards))
+	for i, fc := range flashcards {
+		layouts = append(layouts, models.MindMapNodeLayout{
+			FlashcardID: fc.ID,
+			XPosition:   positions[i][0],
+			YPosition:   positions[i][1],
+			Data:        layoutData(fc.Term),
+		})
+	}
+
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	title := req.Title
+	if title == "" {
+		title = template.Name
+	}
+	mindMap := models.MindMap{
+		Title:    title,
+		SetID:    set.ID,
+		UserID:   set.UserID,
+		IsPublic: req.IsPublic,
+		PublicID: publicID,
+
+		OrganizationID: set.OrganizationID,
+	}
+	if err := createMindMapGraph(db.DB, &mindMap, nil, connections, layouts); err != nil {
+		http.Error(w, "Failed to create mind map", http.StatusInternalServerError)
+		return
+	}
+
+	type MindMapFull struct {
+		models.MindMap
+		NodeLayouts []models.MindMapNodeLayout `json:"nodeLayouts"`
+	}
+	mindMap.Connections = connections
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(MindMapFull{MindMap: mindMap, NodeLayouts: layouts})
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	defaultSimilarityThreshold = 0.2
+	defaultSimilarEdgesPerCard = 2
+	maxSimilarEdgesPerCard     = 5
+
+	relationshipMentions = "refers to"
+	relationshipSimilar  = "similar to"
+
+	generatedCardWidth    = 220.0
+	generatedCardHeight   = 120.0
+	generatedGroupMargin  = 40.0
+	generatedGroupHeader  = 50.0
+	generatedGroupGap     = 120.0
+	generatedGroupsPerRow = 3
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "which": true, "with": true, "can": true, "not": true, "but": true, "into": true,
+	"their": true, "they": true, "these": true, "those": true, "when": true, "where": true, "what": true,
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if len(field) > 1 && !stopWords[field] {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+// tfidfVectors builds an L2-normalised TF-IDF vector for each document.
+func tfidfVectors(documents []string) []map[string]float64 {
+	termCounts := make([]map[string]float64, len(documents))
+	documentFrequency := make(map[string]int)
+	for i, doc := range documents {
+		termCounts[i] = make(map[string]float64)
+		for _, token := range tokenize(doc) {
+			termCounts[i][token]++
+		}
+		for token := range termCounts[i] {
+			documentFrequency[token]++
+		}
+	}
+
+	n := float64(len(documents))
+	vectors := make([]map[string]float64, len(documents))
+	for i, counts := range termCounts {
+		vector := make(map[string]float64, len(counts))
+		var norm float64
+		for token, count := range counts {
+			weight := (1 + math.Log(count)) * math.Log((1+n)/(1+float64(documentFrequency[token])))
+			vector[token] = weight
+			norm += weight * weight
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for token := range vector {
+				vector[token] /= norm
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+func cosineSimilarity(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var dot float64
+	for token, weight := range a {
+		dot += weight * b[token]
+	}
+	return dot
+}
+
+// POST /api/sets/{setID}/mindmaps/generate
+func (db *DBHandler) GenerateMindMap(w http.ResponseWriter, r *http.Request) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	var req struct {
+		Title               string   `json:"Title,omitempty"`
+		IsPublic            bool     `json:"IsPublic"`
+		SimilarityThreshold *float64 `json:"SimilarityThreshold,omitempty"`
+		SimilarEdgesPerCard *int     `json:"SimilarEdgesPerCard,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	threshold := defaultSimilarityThreshold
+	if req.SimilarityThreshold != nil {
+		if *req.SimilarityThreshold < 0 || *req.SimilarityThreshold > 1 {
+			http.Error(w, "SimilarityThreshold must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		threshold = *req.SimilarityThreshold
+	}
+	perCard := defaultSimilarEdgesPerCard
+	if req.SimilarEdgesPerCard != nil {
+		perCard = max(0, min(*req.SimilarEdgesPerCard, maxSimilarEdgesPerCard))
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID)).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	if len(flashcards) == 0 {
+		http.Error(w, "Set has no flashcards to generate a mind map from", http.StatusBadRequest)
+		return
+	}
+
+	var connections []models.MindMapConnection
+	linked := make(map[[2]uint]bool)
+	link := func(source, target uint, relationship string) {
+		pair := [2]uint{min(source, target), max(source, target)}
+		if source == target || linked[pair] {
+			return
+		}
+		linked[pair] = true
+		connections = append(connections, models.MindMapConnection{
+			SourceID:     source,
+			TargetID:     target,
+			Relationship: relationship,
+		})
+	}
+
+	// A card whose solution uses another card's term builds on that concept
+	patterns := make([]*regexp.Regexp, len(flashcards))
+	for i, fc := range flashcards {
+		patterns[i] = termPattern(fc.Term)
+	}
+	for _, card := range flashcards {
+		for i, other := range flashcards {
+			if card.ID != other.ID && patterns[i] != nil && patterns[i].MatchString(card.Solution) {
+				link(card.ID, other.ID, relationshipMentions)
+			}
+		}
+	}
+
+	documents := make([]string, len(flashcards))
+	for i, fc := range flashcards {
+		documents[i] = fc.Term + " " + fc.Solution
+	}
+	vectors := tfidfVectors(documents)
+	for i := range flashcards {
+		type neighbour struct {
+			index int
+			score float64
+		}
+		var neighbours []neighbour
+		for j := range flashcards {
+			if i == j {
+				continue
+			}
+			if score := cosineSimilarity(vectors[i], vectors[j]); score >= threshold && score > 0 {
+				neighbours = append(neighbours, neighbour{j, score})
+			}
+		}
+		sort.Slice(neighbours, func(a, b int) bool { return neighbours[a].score > neighbours[b].score })
+		for k := 0; k < len(neighbours) && k < perCard; k++ {
+			link(flashcards[i].ID, flashcards[neighbours[k].index].ID, relationshipSimilar)
+		}
+	}
+
+	// Group cards by concept, preferring the set's concepts in their order and
+	// falling back to the learning goal text in the order it first appears
+	var concepts []models.Concept
+	if err := db.Where("set_id = ?", set.ID).Order("position asc, id asc").Find(&concepts).Error; err != nil {
+		http.Error(w, "Failed to fetch concepts", http.StatusInternalServerError)
+		return
+	}
+	var conceptOrder []string
+	conceptLabels := make(map[string]string)
+	cardsByConcept := make(map[string][]models.Flashcard)
+	for _, concept := range concepts {
+		key := fmt.Sprintf("concept:%d", concept.ID)
+		conceptOrder = append(conceptOrder, key)
+		conceptLabels[key] = concept.Name
+	}
+	for _, fc := range flashcards {
+		key := normalizeTerm(fc.Concept)
+		if fc.ConceptID != nil {
+			key = fmt.Sprintf("concept:%d", *fc.ConceptID)
+		}
+		if _, seen := conceptLabels[key]; !seen {
+			conceptOrder = append(conceptOrder, key)
+			conceptLabels[key] = strings.TrimSpace(fc.Concept)
+		}
+		cardsByConcept[key] = append(cardsByConcept[key], fc)
+	}
+
+	// Lay groups out on a grid, each sized to fit its cards in a square-ish block.
+	// Group nodes get temporary IDs that createMindMapGraph replaces.
+	var nodes []models.MindMapNode
+	var layouts []models.MindMapNodeLayout
+	x, y, rowHeight := 0.0, 0.0, 0.0
+	placed := 0
+	for _, key := range conceptOrder {
+		cards := cardsByConcept[key]
+		if len(cards) == 0 {
+			continue
+		}
+		columns := int(math.Ceil(math.Sqrt(float64(len(cards)))))
+		rows := int(math.Ceil(float64(len(cards)) / float64(columns)))
+		width := float64(columns)*generatedCardWidth + 2*generatedGroupMargin
+		height := float64(rows)*generatedCardHeight + 2*generatedGroupMargin + generatedGroupHeader
+
+		var groupID *uint
+		if key != "" {
+			id := uint(placed + 1)
+			groupID = &id
+			node := models.MindMapNode{
+				Kind:      models.MindMapNodeGroup,
+				Label:     conceptLabels[key],
+				XPosition: x,
+				YPosition: y,
+				Width:     width,
+				Height:    height,
+			}
+			node.ID = id
+			nodes = append(nodes, node)
+		}
+		for j, fc := range cards {
+			layouts = append(layouts, models.MindMapNodeLayout{
+				FlashcardID: fc.ID,
+				XPosition:   x + generatedGroupMargin + float64(j%columns)*generatedCardWidth,
+				YPosition:   y + generatedGroupMargin + generatedGroupHeader + float64(j/columns)*generatedCardHeight,
+				Data:        layoutData(fc.Term),
+				GroupID:     groupID,
+			})
+		}
+
+		rowHeight = max(rowHeight, height)
+		x += width + generatedGroupGap
+		placed++
+		if placed%generatedGroupsPerRow == 0 {
+			x = 0
+			y += rowHeight + generatedGroupGap
+			rowHeight = 0
+		}
+	}
+
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	title := req.Title
+	if title == "" {
+		title = "Generated map"
+	}
+	mindMap := models.MindMap{
+		Title:    title,
+		SetID:    set.ID,
+		UserID:   set.UserID,
+		IsPublic: req.IsPublic,
+		PublicID: publicID,
+
+		OrganizationID: set.OrganizationID,
+	}
+	if err := createMindMapGraph(db.DB, &mindMap, nodes, connections, layouts); err != nil {
+		http.Error(w, "Failed to create mind map", http.StatusInternalServerError)
+		return
+	}
+
+	type MindMapFull struct {
+		models.MindMap
+		NodeLayouts []models.MindMapNodeLayout `json:"nodeLayouts"`
+	}
+	mindMap.Connections = connections
+	mindMap.Nodes = nodes
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(MindMapFull{MindMap: mindMap, NodeLayouts: layouts})
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+// maxGroupDepth bounds how far validateMindMapNode walks up nested groups.
+const maxGroupDepth = 50
+
+// validateMindMapNode checks a node's kind, text lengths and parent group.
+func validateMindMapNode(db *gorm.DB, node *models.MindMapNode) error {
+	switch node.Kind {
+	case models.MindMapNodeText, models.MindMapNodeGroup, models.MindMapNodeNote:
+	default:
+		return errors.New("kind must be text, group or note")
+	}
+	if utf8.RuneCountInString(node.Label) > 200 {
+		return errors.New("label must be at most 200 characters")
+	}
+	if utf8.RuneCountInString(node.Body) > 2500 {
+		return errors.New("body must be at most 2500 characters")
+	}
+	if node.ParentID != nil {
+		if node.ID != 0 && *node.ParentID == node.ID {
+			return errors.New("a node can't contain itself")
+		}
+		var parent models.MindMapNode
+		if err := db.Where("id = ? AND mind_map_id = ?", *node.ParentID, node.MindMapID).First(&parent).Error; err != nil {
+			return errors.New("parent node not found in mind map")
+		}
+		if parent.Kind != models.MindMapNodeGroup {
+			return errors.New("parent node must be a group")
+		}
+		// Walk up the containing groups so a move can't create a loop
+		for depth := 0; parent.ParentID != nil; depth++ {
+			if *parent.ParentID == node.ID || depth == maxGroupDepth {
+				return errors.New("groups can't contain themselves")
+			}
+			var next models.MindMapNode
+			if err := db.First(&next, *parent.ParentID).Error; err != nil {
+				break
+			}
+			parent = next
+		}
+	}
+	return nil
+}
+
+// validateEdgeEndpoints checks each end of conn is either a flashcard in the
+// set or a node in the mind map, but not both.
+func validateEdgeEndpoints(tx *gorm.DB, mindMap *models.MindMap, conn *models.MindMapConnection) error {
+	check := func(flashcardID uint, nodeID *uint) error {
+		if (flashcardID == 0) == (nodeID == nil) {
+			return invalidOp("each end of an edge needs exactly one of a flashcard or a node")
+		}
+		if nodeID == nil {
+			return flashcardInSet(tx, flashcardID, mindMap.SetID)
+		}
+		var count int64
+		if err := tx.Model(&models.MindMapNode{}).Where("id = ? AND mind_map_id = ?", *nodeID, mindMap.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return invalidOp("node %d is not in this mind map", *nodeID)
+		}
+		return nil
+	}
+	if err := check(conn.SourceID, conn.SourceNodeID); err != nil {
+		return err
+	}
+	return check(conn.TargetID, conn.TargetNodeID)
+}
+
+// loadOwnedMindMap resolves the set and mind map in the path and checks the caller owns them.
+func (db *DBHandler) loadOwnedMindMap(w http.ResponseWriter, r *http.Request) (*models.MindMap, bool) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return nil, false
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return nil, false
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return nil, false
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return &mindMap, true
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/nodes
+func (db *DBHandler) GetMindMapNodes(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var nodes []models.MindMapNode
+	if err := db.Where("mind_map_id = ?", mindMap.ID).Find(&nodes).Error; err != nil {
+		http.Error(w, "Failed to fetch nodes", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// applyNodeOp applies a single map node operation for the caller through the
+// same log as PATCH /graph, so collaborators and snapshots see it, and
+// returns the node it left behind. It writes the error response itself.
+func (db *DBHandler) applyNodeOp(w http.ResponseWriter, r *http.Request, mindMap *models.MindMap, op mindMapOp) (*models.MindMapNode, bool) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	applied, err := applyMindMapOps(db.DB, mindMap, user.ID, "", []mindMapOp{op})
+	if errors.Is(err, errInvalidOp) {
+		http.Error(w, strings.TrimPrefix(err.Error(), "operation 0: "), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to save node", http.StatusInternalServerError)
+		return nil, false
+	}
+	mindMapEvents.publishOps(mindMap.ID, applied)
+
+	var node models.MindMapNode
+	if last := applied[len(applied)-1]; last.Value != nil {
+		json.Unmarshal(last.Value, &node)
+	}
+	return &node, true
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/nodes
+func (db *DBHandler) CreateMindMapNode(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadOwnedMindMap(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Kind      string  `json:"Kind"`
+		Label     string  `json:"Label"`
+		Body      string  `json:"Body"`
+		ParentID  *uint   `json:"ParentID,omitempty"`
+		XPosition float64 `json:"XPosition"`
+		YPosition float64 `json:"YPosition"`
+		Width     float64 `json:"Width"`
+		Height    float64 `json:"Height"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	value, _ := json.Marshal(models.MindMapNode{
+		Kind:      req.Kind,
+		Label:     req.Label,
+		Body:      req.Body,
+		ParentID:  req.ParentID,
+		XPosition: req.XPosition,
+		YPosition: req.YPosition,
+		Width:     req.Width,
+		Height:    req.Height,
+	})
+	node, ok := db.applyNodeOp(w, r, mindMap, mindMapOp{Op: opAdd, Path: "/mapnodes", Value: value})
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(node)
+}
+
+// PUT /api/sets/{setID}/mindmaps/{mindMapID}/nodes/{nodeID}
+func (db *DBHandler) UpdateMindMapNode(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadOwnedMindMap(w, r)
+	if !ok {
+		return
+	}
+	var node models.MindMapNode
+	if err := db.Where("public_id = ? AND mind_map_id = ?", r.PathValue("nodeID"), mindMap.ID).First(&node).Error; err != nil {
+		http.Error(w, "Node not found in mind map", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Label       *string  `json:"Label,omitempty"`
+		Body        *string  `json:"Body,omitempty"`
+		ParentID    *uint    `json:"ParentID,omitempty"`
+		ClearParent bool     `json:"ClearParent"`
+		XPosition   *float64 `json:"XPosition,omitempty"`
+		YPosition   *float64 `json:"YPosition,omitempty"`
+		Width       *float64 `json:"Width,omitempty"`
+		Height      *float64 `json:"Height,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// Only the fields sent are changed
+	changes := make(map[string]any)
+	if req.Label != nil {
+		changes["Label"] = *req.Label
+	}
+	if req.Body != nil {
+		changes["Body"] = *req.Body
+	}
+	if req.ClearParent {
+		changes["ParentID"] = nil
+	} else if req.ParentID != nil {
+		changes["ParentID"] = *req.ParentID
+	}
+	if req.XPosition != nil {
+		changes["XPosition"] = *req.XPosition
+	}
+	if req.YPosition != nil {
+		changes["YPosition"] = *req.YPosition
+	}
+	if req.Width != nil {
+		changes["Width"] = *req.Width
+	}
+	if req.Height != nil {
+		changes["Height"] = *req.Height
+	}
+	value, _ := json.Marshal(changes)
+	updated, ok := db.applyNodeOp(w, r, mindMap, mindMapOp{Op: opReplace, Path: fmt.Sprintf("/mapnodes/%d", node.ID), Value: value})
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DELETE /api/sets/{setID}/mindmaps/{mindMapID}/nodes/{nodeID}
+//
+// Edges attached to the node are removed and a group's members are taken out
+// of it, each as its own operation.
+func (db *DBHandler) DeleteMindMapNode(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadOwnedMindMap(w, r)
+	if !ok {
+		return
+	}
+	var node models.MindMapNode
+	if err := db.Where("public_id = ? AND mind_map_id = ?", r.PathValue("nodeID"), mindMap.ID).First(&node).Error; err != nil {
+		http.Error(w, "Node not found in mind map", http.StatusNotFound)
+		return
+	}
+	if _, ok := db.applyNodeOp(w, r, mindMap, mindMapOp{Op: opRemove, Path: fmt.Sprintf("/mapnodes/%d", node.ID)}); !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+
+	maxOpsPerRequest = 500
+)
+
+var errInvalidOp = errors.New("invalid operation")
+
+// mindMapOp is a JSON Patch style edit to a mind map. Paths address
+// flashcard nodes by flashcard ID, edges by connection ID and the map's own
+// text, group and note nodes by MindMapNode ID:
+//
+//	add     /nodes                           {FlashcardID, XPosition, YPosition, Data}
+//	replace /nodes/{flashcardID}/position    {XPosition, YPosition}
+//	replace /nodes/{flashcardID}/data        "..."
+//	replace /nodes/{flashcardID}/group       group MindMapNode ID or null
+//	remove  /nodes/{flashcardID}
+//	add     /edges                           {SourceID|SourceNodeID, TargetID|TargetNodeID, Relationship, RelationshipType, Note}
+//	replace /edges/{connectionID}/relationship "..."
+//	replace /edges/{connectionID}/type         relationship type key, "" to clear
+//	replace /edges/{connectionID}/note         "..."
+//	remove  /edges/{connectionID}
+//	add     /mapnodes                        {Kind, Label, Body, ParentID, XPosition, YPosition, Width, Height}
+//	replace /mapnodes/{nodeID}               any of {Label, Body, ParentID, XPosition, YPosition, Width, Height}
+//	remove  /mapnodes/{nodeID}
+//	replace /nodes, /edges or /mapnodes      full list, recorded by the bulk PUT endpoints and restores
+//
+// Removing a node first records the edits that detach everything attached to
+// it, so the log alone reproduces the map.
+type mindMapOp struct {
+	Seq       uint            `json:"seq,omitempty"`
+	ClientSeq uint            `json:"clientSeq,omitempty"`
+	Op        string          `json:"op"`
+	Path      string          `json:"path"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+func opFromModel(m models.MindMapOperation) mindMapOp {
+	op := mindMapOp{Seq: m.Seq, ClientSeq: m.ClientSeq, Op: m.Op, Path: m.Path}
+	if m.Value != "" {
+		op.Value = json.RawMessage(m.Value)
+	}
+	return op
+}
+
+func invalidOp(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errInvalidOp, fmt.Sprintf(format, args...))
+}
+
+func parseOpID(segment string) (uint, error) {
+	id, err := strconv.ParseUint(segment, 10, 64)
+	if err != nil || id == 0 {
+		return 0, invalidOp("bad id %q", segment)
+	}
+	return uint(id), nil
+}
+
+func flashcardInSet(tx *gorm.DB, flashcardID, setID uint) error {
+	var count int64
+	if err := tx.Model(&models.Flashcard{}).Where("id = ? AND set_id = ?", flashcardID, setID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return invalidOp("flashcard %d is not in this set", flashcardID)
+	}
+	return nil
+}
+
+// groupInMap checks that groupID is a group node of the mind map.
+func groupInMap(tx *gorm.DB, groupID, mindMapID uint) error {
+	var count int64
+	if err := tx.Model(&models.MindMapNode{}).Where("id = ? AND mind_map_id = ? AND kind = ?", groupID, mindMapID, models.MindMapNodeGroup).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return invalidOp("group %d not found", groupID)
+	}
+	return nil
+}
+
+// applyMindMapOp applies a single operation inside tx. Values for added rows
+// are rewritten to the stored row so other clients learn the new IDs.
+func applyMindMapOp(tx *gorm.DB, mindMap *models.MindMap, op *mindMapOp) error {
+	segments := strings.Split(strings.Trim(op.Path, "/"), "/")
+	if len(segments) == 0 || (segments[0] != "nodes" && segments[0] != "edges" && segments[0] != "mapnodes") {
+		return invalidOp("unknown path %q", op.Path)
+	}
+
+	switch {
+	case segments[0] == "nodes" && op.Op == opAdd && len(segments) == 1:
+		var layout models.MindMapNodeLayout
+		if err := json.Unmarshal(op.Value, &layout); err != nil {
+			return invalidOp("bad node value: %v", err)
+		}
+		if err := flashcardInSet(tx, layout.FlashcardID, mindMap.SetID); err != nil {
+			return err
+		}
+		if layout.GroupID != nil {
+			if err := groupInMap(tx, *layout.GroupID, mindMap.ID); err != nil {
+				return err
+			}
+		}
+		var count int64
+		if err := tx.Model(&models.MindMapNodeLayout{}).Where("mind_map_id = ? AND flashcard_id = ?", mindMap.ID, layout.FlashcardID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return invalidOp("node %d already exists", layout.FlashcardID)
+		}
+		layout.ID = 0
+		layout.MindMapID = mindMap.ID
+		if err := tx.Create(&layout).Error; err != nil {
+			return err
+		}
+		op.Value, _ = json.Marshal(layout)
+
+	case segments[0] == "nodes" && op.Op == opReplace && len(segments) == 3:
+		flashcardID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		var layout models.MindMapNodeLayout
+		if err := tx.Where("mind_map_id = ? AND flashcard_id = ?", mindMap.ID, flashcardID).First(&layout).Error; err != nil {
+			return invalidOp("node %d not found", flashcardID)
+		}
+		switch segments[2] {
+		case "position":
+			var position struct {
+				XPosition float64
+				YPosition float64
+			}
+			if err := json.Unmarshal(op.Value, &position); err != nil {
+				return invalidOp("bad position: %v", err)
+			}
+			layout.XPosition = position.XPosition
+			layout.YPosition = position.YPosition
+		case "data":
+			if err := json.Unmarshal(op.Value, &layout.Data); err != nil {
+				return invalidOp("bad data: %v", err)
+			}
+		case "group":
+			if err := json.Unmarshal(op.Value, &layout.GroupID); err != nil {
+				return invalidOp("bad group: %v", err)
+			}
+			if layout.GroupID != nil {
+				if err := groupInMap(tx, *layout.GroupID, mindMap.ID); err != nil {
+					return err
+				}
+			}
+		default:
+			return invalidOp("unknown node field %q", segments[2])
+		}
+		if err := tx.Save(&layout).Error; err != nil {
+			return err
+		}
+
+	case segments[0] == "nodes" && op.Op == opRemove && len(segments) == 2:
+		flashcardID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		result := tx.Where("mind_map_id = ? AND flashcard_id = ?", mindMap.ID, flashcardID).Delete(&models.MindMapNodeLayout{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidOp("node %d not found", flashcardID)
+		}
+
+	case segments[0] == "edges" && op.Op == opAdd && len(segments) == 1:
+		var conn models.MindMapConnection
+		if err := json.Unmarshal(op.Value, &conn); err != nil {
+			return invalidOp("bad edge value: %v", err)
+		}
+		if err := validateEdgeEndpoints(tx, mindMap, &conn); err != nil {
+			return err
+		}
+		vocabulary, err := relationshipVocabulary(tx, mindMap.SetID)
+		if err != nil {
+			return err
+		}
+		if err := typeConnection(vocabulary, &conn); err != nil {
+			return err
+		}
+		conn.ID = 0
+		conn.MindMapID = mindMap.ID
+		if err := tx.Omit(clause.Associations).Create(&conn).Error; err != nil {
+			return err
+		}
+		op.Value, _ = json.Marshal(conn)
+
+	case segments[0] == "edges" && op.Op == opReplace && len(segments) == 3 && segments[2] == "relationship":
+		connectionID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		var relationship string
+		if err := json.Unmarshal(op.Value, &relationship); err != nil {
+			return invalidOp("bad relationship: %v", err)
+		}
+		result := tx.Model(&models.MindMapConnection{}).Where("id = ? AND mind_map_id = ?", connectionID, mindMap.ID).Update("relationship", relationship)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidOp("edge %d not found", connectionID)
+		}
+
+	case segments[0] == "edges" && op.Op == opReplace && len(segments) == 3 && (segments[2] == "type" || segments[2] == "note"):
+		connectionID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		var value string
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return invalidOp("bad %s: %v", segments[2], err)
+		}
+		column := "note"
+		if segments[2] == "type" {
+			column = "relationship_type"
+			if value != "" {
+				vocabulary, err := relationshipVocabulary(tx, mindMap.SetID)
+				if err != nil {
+					return err
+				}
+				if findRelationshipType(vocabulary, value) == nil {
+					return invalidOp("unknown relationship type %q", value)
+				}
+			}
+		} else if len(value) > 500 {
+			return invalidOp("note must be at most 500 characters")
+		}
+		result := tx.Model(&models.MindMapConnection{}).Where("id = ? AND mind_map_id = ?", connectionID, mindMap.ID).Update(column, value)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidOp("edge %d not found", connectionID)
+		}
+
+	case segments[0] == "edges" && op.Op == opRemove && len(segments) == 2:
+		connectionID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND mind_map_id = ?", connectionID, mindMap.ID).Delete(&models.MindMapConnection{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidOp("edge %d not found", connectionID)
+		}
+
+	case segments[0] == "mapnodes" && op.Op == opAdd && len(segments) == 1:
+		var node models.MindMapNode
+		if err := json.Unmarshal(op.Value, &node); err != nil {
+			return invalidOp("bad node value: %v", err)
+		}
+		publicID, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		node.Model = gorm.Model{}
+		node.MindMapID = mindMap.ID
+		node.PublicID = publicID
+		if err := validateMindMapNode(tx, &node); err != nil {
+			return invalidOp("%v", err)
+		}
+		if err := tx.Create(&node).Error; err != nil {
+			return err
+		}
+		op.Value, _ = json.Marshal(node)
+
+	case segments[0] == "mapnodes" && op.Op == opReplace && len(segments) == 2:
+		nodeID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		var node models.MindMapNode
+		if err := tx.Where("id = ? AND mind_map_id = ?", nodeID, mindMap.ID).First(&node).Error; err != nil {
+			return invalidOp("map node %d not found", nodeID)
+		}
+		// Fields missing from the value keep their current values; identity
+		// and kind never change
+		changed := node
+		if err := json.Unmarshal(op.Value, &changed); err != nil {
+			return invalidOp("bad node value: %v", err)
+		}
+		node.Label, node.Body, node.ParentID = changed.Label, changed.Body, changed.ParentID
+		node.XPosition, node.YPosition = changed.XPosition, changed.YPosition
+		node.Width, node.Height = changed.Width, changed.Height
+		if err := validateMindMapNode(tx, &node); err != nil {
+			return invalidOp("%v", err)
+		}
+		if err := tx.Save(&node).Error; err != nil {
+			return err
+		}
+		op.Value, _ = json.Marshal(node)
+
+	case segments[0] == "mapnodes" && op.Op == opRemove && len(segments) == 2:
+		nodeID, err := parseOpID(segments[1])
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND mind_map_id = ?", nodeID, mindMap.ID).Delete(&models.MindMapNode{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidOp("map node %d not found", nodeID)
+		}
+
+	default:
+		return invalidOp("unsupported %s on %q", op.Op, op.Path)
+	}
+	return nil
+}
+
+// detachMindMapNodeOps returns the operations that have to precede op when it
+// removes a node: removing the edges attached to it and, for a group, taking
+// its members out. Edges can't outlive the nodes they join.
+func detachMindMapNodeOps(tx *gorm.DB, mindMap *models.MindMap, op mindMapOp) ([]mindMapOp, error) {
+	segments := strings.Split(strings.Trim(op.Path, "/"), "/")
+	if op.Op != opRemove || len(segments) != 2 || (segments[0] != "nodes" && segments[0] != "mapnodes") {
+		return nil, nil
+	}
+	id, err := parseOpID(segments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var connectionIDs []uint
+	edges := tx.Model(&models.MindMapConnection{}).Where("mind_map_id = ?", mindMap.ID)
+	if segments[0] == "nodes" {
+		edges = edges.Where("(source_id = ? AND source_node_id IS NULL) OR (target_id = ? AND target_node_id IS NULL)", id, id)
+	} else {
+		edges = edges.Where("source_node_id = ? OR target_node_id = ?", id, id)
+	}
+	if err := edges.Order("id asc").Pluck("id", &connectionIDs).Error; err != nil {
+		return nil, err
+	}
+	var implied []mindMapOp
+	for _, connectionID := range connectionIDs {
+		implied = append(implied, mindMapOp{ClientSeq: op.ClientSeq, Op: opRemove, Path: fmt.Sprintf("/edges/%d", connectionID)})
+	}
+	if segments[0] == "nodes" {
+		return implied, nil
+	}
+
+	null := json.RawMessage("null")
+	var members []uint
+	if err := tx.Model(&models.MindMapNodeLayout{}).Where("mind_map_id = ? AND group_id = ?", mindMap.ID, id).
+		Order("flashcard_id asc").Pluck("flashcard_id", &members).Error; err != nil {
+		return nil, err
+	}
+	for _, flashcardID := range members {
+		implied = append(implied, mindMapOp{ClientSeq: op.ClientSeq, Op: opReplace, Path: fmt.Sprintf("/nodes/%d/group", flashcardID), Value: null})
+	}
+	var children []uint
+	if err := tx.Model(&models.MindMapNode{}).Where("mind_map_id = ? AND parent_id = ?", mindMap.ID, id).
+		Order("id asc").Pluck("id", &children).Error; err != nil {
+		return nil, err
+	}
+	for _, childID := range children {
+		implied = append(implied, mindMapOp{ClientSeq: op.ClientSeq, Op: opReplace, Path: fmt.Sprintf("/mapnodes/%d", childID), Value: json.RawMessage(`{"ParentID":null}`)})
+	}
+	return implied, nil
+}
+
+// lockMindMap reloads the mind map inside tx with a row lock so concurrent
+// writers are given consecutive sequence numbers.
+func lockMindMap(tx *gorm.DB, mindMap *models.MindMap) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(mindMap, mindMap.ID).Error
+}
+
+// recordMindMapOp assigns the next sequence number to op and stores it. The
+// caller must hold the lock taken by lockMindMap.
+func recordMindMapOp(tx *gorm.DB, mindMap *models.MindMap, userID uint, clientID string, op *mindMapOp) error {
+	op.Seq = mindMap.Seq + 1
+	record := models.MindMapOperation{
+		MindMapID: mindMap.ID,
+		Seq:       op.Seq,
+		UserID:    userID,
+		ClientID:  clientID,
+		ClientSeq: op.ClientSeq,
+		Op:        op.Op,
+		Path:      op.Path,
+		Value:     string(op.Value),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return err
+	}
+	mindMap.Seq = op.Seq
+	return tx.Model(mindMap).Update("seq", mindMap.Seq).Error
+}
+
+// lastClientSeq returns the highest client sequence already applied for clientID.
+func lastClientSeq(tx *gorm.DB, mindMapID uint, clientID string) (uint, error) {
+	if clientID == "" {
+		return 0, nil
+	}
+	var last uint
+	err := tx.Model(&models.MindMapOperation{}).
+		Where("mind_map_id = ? AND client_id = ?", mindMapID, clientID).
+		Select("COALESCE(MAX(client_seq), 0)").Scan(&last).Error
+	return last, err
+}
+
+// applyMindMapOps applies ops in one transaction, skipping any the client has
+// already had applied, and returns the operations that were recorded.
+func applyMindMapOps(db *gorm.DB, mindMap *models.MindMap, userID uint, clientID string, ops []mindMapOp) ([]mindMapOp, error) {
+	var applied []mindMapOp
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMindMap(tx, mindMap); err != nil {
+			return err
+		}
+		last, err := lastClientSeq(tx, mindMap.ID, clientID)
+		if err != nil {
+			return err
+		}
+		for i := range ops {
+			op := ops[i]
+			if clientID != "" && op.ClientSeq != 0 && op.ClientSeq <= last {
+				continue
+			}
+			implied, err := detachMindMapNodeOps(tx, mindMap, op)
+			if err != nil {
+				return fmt.Errorf("operation %d: %w", i, err)
+			}
+			for _, step := range append(implied, op) {
+				if err := applyMindMapOp(tx, mindMap, &step); err != nil {
+					return fmt.Errorf("operation %d: %w", i, err)
+				}
+				if err := recordMindMapOp(tx, mindMap, userID, clientID, &step); err != nil {
+					return err
+				}
+				applied = append(applied, step)
+			}
+		}
+		if len(applied) == 0 {
+			return nil
+		}
+		due, err := patchSnapshotDue(tx, mindMap, userID)
+		if err != nil || !due {
+			return err
+		}
+		return snapshotMindMap(tx, mindMap, userID, snapshotReasonPatch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// PATCH /api/sets/{setID}/mindmaps/{mindMapID}/graph
+func (db *DBHandler) PatchMindMapGraph(w http.ResponseWriter, r *http.Request) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ClientID string      `json:"clientID"`
+		Ops      []mindMapOp `json:"ops"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Ops) == 0 || len(req.Ops) > maxOpsPerRequest {
+		http.Error(w, fmt.Sprintf("Between 1 and %d operations are required", maxOpsPerRequest), http.StatusBadRequest)
+		return
+	}
+
+	applied, err := applyMindMapOps(db.DB, &mindMap, user.ID, req.ClientID, req.Ops)
+	if errors.Is(err, errInvalidOp) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to apply operations", http.StatusInternalServerError)
+		return
+	}
+
+	mindMapEvents.publishOps(mindMap.ID, applied)
+
+	lastApplied, _ := lastClientSeq(db.DB, mindMap.ID, req.ClientID)
+	type PatchResponse struct {
+		Seq           uint        `json:"seq"`
+		LastClientSeq uint        `json:"lastClientSeq"`
+		Applied       []mindMapOp `json:"applied"`
+	}
+	if applied == nil {
+		applied = []mindMapOp{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PatchResponse{
+		Seq:           mindMap.Seq,
+		LastClientSeq: lastApplied,
+		Applied:       applied,
+	})
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/ops?since=&clientID=
+func (db *DBHandler) GetMindMapOps(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "since must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var records []models.MindMapOperation
+	if err := db.Where("mind_map_id = ? AND seq > ?", mindMap.ID, since).Order("seq asc").Limit(maxOpsPerRequest).Find(&records).Error; err != nil {
+		http.Error(w, "Failed to fetch operations", http.StatusInternalServerError)
+		return
+	}
+	ops := make([]mindMapOp, 0, len(records))
+	for _, record := range records {
+		ops = append(ops, opFromModel(record))
+	}
+	lastApplied, err := lastClientSeq(db.DB, mindMap.ID, r.URL.Query().Get("clientID"))
+	if err != nil {
+		http.Error(w, "Failed to fetch operations", http.StatusInternalServerError)
+		return
+	}
+
+	type OpsResponse struct {
+		Seq           uint        `json:"seq"`
+		LastClientSeq uint        `json:"lastClientSeq"`
+		Ops           []mindMapOp `json:"ops"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpsResponse{
+		Seq:           mindMap.Seq,
+		LastClientSeq: lastApplied,
+		Ops:           ops,
+	})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+const (
+	defaultPathCount = 3
+	maxPathCount     = 10
+)
+
+// conceptPath is a chain of connections leading from one flashcard to another.
+type conceptPath []*models.MindMapConnection
+
+func (p conceptPath) sharesRoot(other conceptPath, length int) bool {
+	if len(p) < length || len(other) < length {
+		return false
+	}
+	for i := 0; i < length; i++ {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p conceptPath) equals(other conceptPath) bool {
+	return len(p) == len(other) && p.sharesRoot(other, len(p))
+}
+
+// shortestPath runs a breadth-first search along connection direction, skipping
+// blocked nodes and connections. It returns nil when to is unreachable.
+func (g *conceptGraph) shortestPath(from, to uint, blockedNodes map[uint]bool, blockedEdges map[*models.MindMapConnection]bool) conceptPath {
+	if blockedNodes[from] {
+		return nil
+	}
+	if from == to {
+		return conceptPath{}
+	}
+	via := map[uint]*models.MindMapConnection{}
+	visited := map[uint]bool{from: true}
+	queue := []uint{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Out[current] {
+			if visited[e.To] || blockedNodes[e.To] || blockedEdges[e.Connection] {
+				continue
+			}
+			visited[e.To] = true
+			via[e.To] = e.Connection
+			if e.To == to {
+				var path conceptPath
+				for node := to; node != from; node = via[node].SourceID {
+					path = append(conceptPath{via[node]}, path...)
+				}
+				return path
+			}
+			queue = append(queue, e.To)
+		}
+	}
+	return nil
+}
+
+// kShortestPaths returns up to k loopless paths from one card to another in
+// order of length, using Yen's algorithm on top of shortestPath.
+func (g *conceptGraph) kShortestPaths(from, to uint, k int) []conceptPath {
+	first := g.shortestPath(from, to, nil, nil)
+	if len(first) == 0 {
+		return nil
+	}
+	paths := []conceptPath{first}
+	var candidates []conceptPath
+
+	for len(paths) < k {
+		previous := paths[len(paths)-1]
+		for i := range previous {
+			spurNode := previous[i].SourceID
+			root := previous[:i]
+
+			blockedEdges := make(map[*models.MindMapConnection]bool)
+			for _, p := range paths {
+				if len(p) > i && p.sharesRoot(root, i) {
+					blockedEdges[p[i]] = true
+				}
+			}
+			blockedNodes := make(map[uint]bool)
+			for _, conn := range root {
+				blockedNodes[conn.SourceID] = true
+			}
+
+			spur := g.shortestPath(spurNode, to, blockedNodes, blockedEdges)
+			if spur == nil {
+				continue
+			}
+			candidate := append(append(conceptPath{}, root...), spur...)
+			duplicate := false
+			for _, existing := range paths {
+				duplicate = duplicate || existing.equals(candidate)
+			}
+			for _, existing := range candidates {
+				duplicate = duplicate || existing.equals(candidate)
+			}
+			if !duplicate {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return len(candidates[i]) < len(candidates[j]) })
+		paths = append(paths, candidates[0])
+		candidates = candidates[1:]
+	}
+	return paths
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/path?from=&to=&k=
+func (db *DBHandler) GetMindMapPath(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	fromID := r.URL.Query().Get("from")
+	toID := r.URL.Query().Get("to")
+	if fromID == "" || toID == "" {
+		http.Error(w, "from and to flashcard IDs are required", http.StatusBadRequest)
+		return
+	}
+	k := defaultPathCount
+	if raw := r.URL.Query().Get("k"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "k must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		k = min(parsed, maxPathCount)
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	var from, to uint
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+		if fc.PublicID == fromID {
+			from = fc.ID
+		}
+		if fc.PublicID == toID {
+			to = fc.ID
+		}
+	}
+	if from == 0 || to == 0 {
+		http.Error(w, "Flashcard not found in set", http.StatusNotFound)
+		return
+	}
+	if from == to {
+		http.Error(w, "from and to must be different flashcards", http.StatusBadRequest)
+		return
+	}
+
+	type PathStep struct {
+		From         cardRef
+		To           cardRef
+		Relationship string
+	}
+	type Path struct {
+		Length int
+		Steps  []PathStep
+	}
+	type PathResponse struct {
+		From     cardRef
+		To       cardRef
+		Shortest *Path
+		Paths    []Path
+	}
+
+	graph := newConceptGraph(mindMap.Connections, nil)
+	response := PathResponse{
+		From:  newCardRef(from, cardsByID),
+		To:    newCardRef(to, cardsByID),
+		Paths: []Path{},
+	}
+	for _, p := range graph.kShortestPaths(from, to, k) {
+		path := Path{Length: len(p)}
+		for _, conn := range p {
+			path.Steps = append(path.Steps, PathStep{
+				From:         newCardRef(conn.SourceID, cardsByID),
+				To:           newCardRef(conn.TargetID, cardsByID),
+				Relationship: conn.Relationship,
+			})
+		}
+		response.Paths = append(response.Paths, path)
+	}
+	if len(response.Paths) > 0 {
+		response.Shortest = &response.Paths[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+func testConnections(edges [][2]uint) []models.MindMapConnection {
+	connections := make([]models.MindMapConnection, len(edges))
+	for i, e := range edges {
+		connections[i] = models.MindMapConnection{SourceID: e[0], TargetID: e[1]}
+		connections[i].ID = uint(i + 1)
+	}
+	return connections
+}
+
+func TestKShortestPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		edges       [][2]uint
+		from, to    uint
+		k           int
+		wantLengths []int
+	}{
+		{
+			name:        "diamond with shortcut",
+			edges:       [][2]uint{{1, 2}, {2, 4}, {1, 3}, {3, 4}, {1, 4}, {2, 3}},
+			from:        1,
+			to:          4,
+			k:           10,
+			wantLengths: []int{1, 2, 2, 3},
+		},
+		{
+			name:        "k limits results",
+			edges:       [][2]uint{{1, 2}, {2, 4}, {1, 3}, {3, 4}, {1, 4}, {2, 3}},
+			from:        1,
+			to:          4,
+			k:           2,
+			wantLengths: []int{1, 2},
+		},
+		{
+			name:        "cycles are never walked",
+			edges:       [][2]uint{{1, 2}, {2, 1}, {2, 3}, {3, 2}, {3, 4}},
+			from:        1,
+			to:          4,
+			k:           5,
+			wantLengths: []int{3},
+		},
+		{
+			name:        "longer detour found after spur",
+			edges:       [][2]uint{{1, 2}, {2, 3}, {1, 5}, {5, 6}, {6, 2}, {5, 3}},
+			from:        1,
+			to:          3,
+			k:           5,
+			wantLengths: []int{2, 2, 4},
+		},
+		{
+			name:        "direction matters",
+			edges:       [][2]uint{{2, 1}},
+			from:        1,
+			to:          2,
+			k:           3,
+			wantLengths: nil,
+		},
+		{
+			name:        "unreachable",
+			edges:       [][2]uint{{1, 2}, {3, 4}},
+			from:        1,
+			to:          4,
+			k:           3,
+			wantLengths: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := newConceptGraph(testConnections(tt.edges), nil)
+			paths := graph.kShortestPaths(tt.from, tt.to, tt.k)
+			if len(paths) != len(tt.wantLengths) {
+				t.Fatalf("got %d paths, want %d", len(paths), len(tt.wantLengths))
+			}
+			for i, p := range paths {
+				if len(p) != tt.wantLengths[i] {
+					t.Errorf("path %d has length %d, want %d", i, len(p), tt.wantLengths[i])
+				}
+				node := tt.from
+				visited := map[uint]bool{node: true}
+				for _, conn := range p {
+					if conn.SourceID != node {
+						t.Fatalf("path %d is broken at connection %d", i, conn.ID)
+					}
+					node = conn.TargetID
+					if visited[node] {
+						t.Fatalf("path %d visits card %d twice", i, node)
+					}
+					visited[node] = true
+				}
+				if node != tt.to {
+					t.Errorf("path %d ends at card %d, want %d", i, node, tt.to)
+				}
+				for j := 0; j < i; j++ {
+					if p.equals(paths[j]) {
+						t.Errorf("path %d repeats path %d", i, j)
+					}
+				}
+			}
+		})
+	}
+}
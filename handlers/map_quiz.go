@@ -0,0 +1,594 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	quizTypeRelationship = "relationship"
+	quizTypeTarget       = "target"
+	quizTypeMissingEdges = "missing-edges"
+	quizTypeMixed        = "mixed"
+
+	defaultQuizQuestions = 10
+	maxQuizQuestions     = 50
+	quizOptionCount      = 4
+
+	// quizLifetime is how long an issued quiz can be answered
+	quizLifetime = 24 * time.Hour
+)
+
+type quizEdge struct {
+	ConnectionID uint
+	Source       cardRef
+	Target       cardRef
+	Relationship string
+}
+
+type quizQuestion struct {
+	Type                string
+	ConnectionID        uint       `json:",omitempty"`
+	Source              *cardRef   `json:",omitempty"`
+	Target              *cardRef   `json:",omitempty"`
+	Relationship        string     `json:",omitempty"`
+	RelationshipOptions []string   `json:",omitempty"`
+	TargetOptions       []cardRef  `json:",omitempty"`
+	Cards               []cardRef  `json:",omitempty"`
+	VisibleConnections  []quizEdge `json:",omitempty"`
+	HiddenConnectionIDs []uint     `json:",omitempty"`
+}
+
+var errQuizGraded = errors.New("quiz has already been graded")
+
+// issuedQuestion is what a MindMapQuiz remembers about each question it asked
+type issuedQuestion struct {
+	Type                string
+	ConnectionID        uint   `json:",omitempty"`
+	HiddenConnectionIDs []uint `json:",omitempty"`
+}
+
+// buildRelationshipQuestion asks for the label of conn, offering the other
+// labels used in the map as distractors.
+func buildRelationshipQuestion(conn models.MindMapConnection, labels []string, cardsByID map[uint]models.Flashcard) (quizQuestion, bool) {
+	correct := strings.TrimSpace(conn.Relationship)
+	if correct == "" {
+		return quizQuestion{}, false
+	}
+	options := []string{correct}
+	for _, i := range rand.Perm(len(labels)) {
+		if len(options) == quizOptionCount {
+			break
+		}
+		if !strings.EqualFold(labels[i], correct) {
+			options = append(options, labels[i])
+		}
+	}
+	if len(options) < 2 {
+		return quizQuestion{}, false
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	source := newCardRef(conn.SourceID, cardsByID)
+	target := newCardRef(conn.TargetID, cardsByID)
+	return quizQuestion{
+		Type:                quizTypeRelationship,
+		ConnectionID:        conn.ID,
+		Source:              &source,
+		Target:              &target,
+		RelationshipOptions: options,
+	}, true
+}
+
+// buildTargetQuestion asks which card conn's source leads to through its
+// relationship, offering set cards the source isn't linked to as distractors.
+func buildTargetQuestion(conn models.MindMapConnection, connections []models.MindMapConnection, flashcards []models.Flashcard, cardsByID map[uint]models.Flashcard) (quizQuestion, bool) {
+	if strings.TrimSpace(conn.Relationship) == "" {
+		return quizQuestion{}, false
+	}
+	excluded := map[uint]bool{conn.SourceID: true}
+	for _, other := range connections {
+		if other.SourceID == conn.SourceID && strings.EqualFold(other.Relationship, conn.Relationship) {
+			excluded[other.TargetID] = true
+		}
+	}
+	options := []cardRef{newCardRef(conn.TargetID, cardsByID)}
+	for _, i := range rand.Perm(len(flashcards)) {
+		if len(options) == quizOptionCount {
+			break
+		}
+		if !excluded[flashcards[i].ID] {
+			options = append(options, newCardRef(flashcards[i].ID, cardsByID))
+		}
+	}
+	if len(options) < 2 {
+		return quizQuestion{}, false
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	source := newCardRef(conn.SourceID, cardsByID)
+	return quizQuestion{
+		Type:          quizTypeTarget,
+		ConnectionID:  conn.ID,
+		Source:        &source,
+		Relationship:  conn.Relationship,
+		TargetOptions: options,
+	}, true
+}
+
+// buildMissingEdgesQuestion hides count connections and shows the rest of the graph.
+func buildMissingEdgesQuestion(connections []models.MindMapConnection, count int, cardsByID map[uint]models.Flashcard) (quizQuestion, bool) {
+	if len(connections) == 0 {
+		return quizQuestion{}, false
+	}
+	count = max(1, min(count, len(connections)))
+	hidden := make(map[int]bool, count)
+	for _, i := range rand.Perm(len(connections))[:count] {
+		hidden[i] = true
+	}
+
+	question := quizQuestion{Type: quizTypeMissingEdges}
+	seen := make(map[uint]bool)
+	addCard := func(id uint) {
+		if !seen[id] {
+			seen[id] = true
+			question.Cards = append(question.Cards, newCardRef(id, cardsByID))
+		}
+	}
+	for i, conn := range connections {
+		addCard(conn.SourceID)
+		addCard(conn.TargetID)
+		if hidden[i] {
+			question.HiddenConnectionIDs = append(question.HiddenConnectionIDs, conn.ID)
+			continue
+		}
+		question.VisibleConnections = append(question.VisibleConnections, quizEdge{
+			ConnectionID: conn.ID,
+			Source:       newCardRef(conn.SourceID, cardsByID),
+			Target:       newCardRef(conn.TargetID, cardsByID),
+			Relationship: conn.Relationship,
+		})
+	}
+	return question, true
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/quiz?type=&count=
+func (db *DBHandler) GetMindMapQuiz(w http.ResponseWriter, r *http.Request) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+	quizType := r.URL.Query().Get("type")
+	if quizType == "" {
+		quizType = quizTypeMixed
+	}
+	switch quizType {
+	case quizTypeRelationship, quizTypeTarget, quizTypeMissingEdges, quizTypeMixed:
+	default:
+		http.Error(w, "Unknown quiz type", http.StatusBadRequest)
+		return
+	}
+	count := defaultQuizQuestions
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		count = min(parsed, maxQuizQuestions)
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeStudy) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+	}
+
+	// Questions are about flashcards, so edges to labels, groups and notes are skipped
+	mindMap.Connections = cardConnections(mindMap.Connections)
+
+	var labels []string
+	seenLabels := make(map[string]bool)
+	for _, conn := range mindMap.Connections {
+		label := strings.TrimSpace(conn.Relationship)
+		if label != "" && !seenLabels[strings.ToLower(label)] {
+			seenLabels[strings.ToLower(label)] = true
+			labels = append(labels, label)
+		}
+	}
+
+	// Drilling a concept keeps only the edges touching its cards
+	concept, err := conceptFilter(db.DB, r, set.ID)
+	if err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return
+	}
+	if concept != nil {
+		inConcept := func(id uint) bool {
+			fc, ok := cardsByID[id]
+			return ok && fc.ConceptID != nil && *fc.ConceptID == concept.ID
+		}
+		var focused []models.MindMapConnection
+		for _, conn := range mindMap.Connections {
+			if inConcept(conn.SourceID) || inConcept(conn.TargetID) {
+				focused = append(focused, conn)
+			}
+		}
+		mindMap.Connections = focused
+	}
+
+	questions := []quizQuestion{}
+	if quizType == quizTypeMissingEdges {
+		if question, ok := buildMissingEdgesQuestion(mindMap.Connections, count, cardsByID); ok {
+			questions = append(questions, question)
+		}
+	} else {
+		for _, i := range rand.Perm(len(mindMap.Connections)) {
+			if len(questions) == count {
+				break
+			}
+			conn := mindMap.Connections[i]
+			asRelationship := quizType == quizTypeRelationship || (quizType == quizTypeMixed && rand.IntN(2) == 0)
+			var question quizQuestion
+			var ok bool
+			if asRelationship {
+				question, ok = buildRelationshipQuestion(conn, labels, cardsByID)
+			} else {
+				question, ok = buildTargetQuestion(conn, mindMap.Connections, flashcards, cardsByID)
+			}
+			if ok {
+				questions = append(questions, question)
+			}
+		}
+	}
+
+	// Only quizzes issued to a signed-in user can be graded, and only against
+	// the questions they were asked
+	quizID := ""
+	if auth0ID, ok := utils.GetAuth0ID(r); ok && len(questions) > 0 {
+		var user models.User
+		if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err == nil {
+			issued := make([]issuedQuestion, 0, len(questions))
+			for _, question := range questions {
+				issued = append(issued, issuedQuestion{
+					Type:                question.Type,
+					ConnectionID:        question.ConnectionID,
+					HiddenConnectionIDs: question.HiddenConnectionIDs,
+				})
+			}
+			encoded, err := json.Marshal(issued)
+			if err != nil {
+				http.Error(w, "Failed to encode quiz", http.StatusInternalServerError)
+				return
+			}
+			publicID, err := gonanoid.New()
+			if err != nil {
+				http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+				return
+			}
+			quiz := models.MindMapQuiz{
+				PublicID:  publicID,
+				UserID:    user.ID,
+				MindMapID: mindMap.ID,
+				QuizType:  quizType,
+				Questions: string(encoded),
+			}
+			if err := db.Omit("User", "MindMap").Create(&quiz).Error; err != nil {
+				http.Error(w, "Failed to record quiz", http.StatusInternalServerError)
+				return
+			}
+			quizID = quiz.PublicID
+		}
+	}
+
+	type QuizResponse struct {
+		QuizID    string `json:",omitempty"` // Sent back with the answers; only given to signed-in callers
+		MindMapID string
+		Type      string
+		Questions []quizQuestion
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(QuizResponse{
+		QuizID:    quizID,
+		MindMapID: mindMap.PublicID,
+		Type:      quizType,
+		Questions: questions,
+	})
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/quiz/answers
+//
+// Grades the answers to a quiz issued by GetMindMapQuiz. Each quiz can be
+// graded once; questions left unanswered count as wrong.
+func (db *DBHandler) GradeMindMapQuiz(w http.ResponseWriter, r *http.Request) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return
+	}
+
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Preload("Connections").Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	if !mindMap.IsPublic && !db.hasSetRole(r, &set, models.OrgViewer) && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeStudy) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found in database", http.StatusNotFound)
+		return
+	}
+
+	type EdgeAnswer struct {
+		SourceID     string
+		TargetID     string
+		Relationship string
+	}
+	type Answer struct {
+		Type         string
+		ConnectionID uint
+		Relationship string
+		TargetID     string
+		Edges        []EdgeAnswer
+	}
+	var req struct {
+		QuizID  string
+		Answers []Answer
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.QuizID == "" {
+		http.Error(w, "QuizID is required", http.StatusBadRequest)
+		return
+	}
+
+	var quiz models.MindMapQuiz
+	if err := db.Where("public_id = ? AND user_id = ? AND mind_map_id = ?", req.QuizID, user.ID, mindMap.ID).First(&quiz).Error; err != nil {
+		http.Error(w, "Quiz not found", http.StatusNotFound)
+		return
+	}
+	if quiz.GradedAt != nil {
+		http.Error(w, "Quiz has already been graded", http.StatusConflict)
+		return
+	}
+	if time.Since(quiz.CreatedAt) > quizLifetime {
+		http.Error(w, "Quiz has expired", http.StatusGone)
+		return
+	}
+	var issued []issuedQuestion
+	if err := json.Unmarshal([]byte(quiz.Questions), &issued); err != nil {
+		http.Error(w, "Failed to decode quiz", http.StatusInternalServerError)
+		return
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	cardsByID := make(map[uint]models.Flashcard, len(flashcards))
+	for _, fc := range flashcards {
+		cardsByID[fc.ID] = fc
+	}
+	connectionsByID := make(map[uint]models.MindMapConnection, len(mindMap.Connections))
+	for _, conn := range mindMap.Connections {
+		connectionsByID[conn.ID] = conn
+	}
+
+	// Only the first answer to each question counts
+	type questionKey struct {
+		Type         string
+		ConnectionID uint
+	}
+	answers := make(map[questionKey]Answer)
+	for _, answer := range req.Answers {
+		key := questionKey{Type: answer.Type, ConnectionID: answer.ConnectionID}
+		if answer.Type == quizTypeMissingEdges {
+			key.ConnectionID = 0
+		}
+		if _, seen := answers[key]; !seen {
+			answers[key] = answer
+		}
+	}
+
+	type GradedAnswer struct {
+		Type         string
+		ConnectionID uint
+		Correct      bool
+		Expected     quizEdge
+	}
+	type GradeResponse struct {
+		Correct int
+		Total   int
+		Results []GradedAnswer
+		Result  models.MindMapQuizResult
+	}
+	response := GradeResponse{Results: []GradedAnswer{}}
+
+	grade := func(answerType string, conn models.MindMapConnection, correct bool) {
+		response.Total++
+		if correct {
+			response.Correct++
+		}
+		response.Results = append(response.Results, GradedAnswer{
+			Type:         answerType,
+			ConnectionID: conn.ID,
+			Correct:      correct,
+			Expected: quizEdge{
+				ConnectionID: conn.ID,
+				Source:       newCardRef(conn.SourceID, cardsByID),
+				Target:       newCardRef(conn.TargetID, cardsByID),
+				Relationship: conn.Relationship,
+			},
+		})
+	}
+	sameLabel := func(answer, expected string) bool {
+		answer = strings.TrimSpace(answer)
+		return answer != "" && strings.EqualFold(answer, strings.TrimSpace(expected))
+	}
+
+	// Connections deleted since the quiz was issued are left out
+	for _, question := range issued {
+		switch question.Type {
+		case quizTypeRelationship:
+			conn, ok := connectionsByID[question.ConnectionID]
+			if !ok {
+				continue
+			}
+			answer, answered := answers[questionKey{Type: question.Type, ConnectionID: conn.ID}]
+			grade(question.Type, conn, answered && sameLabel(answer.Relationship, conn.Relationship))
+		case quizTypeTarget:
+			conn, ok := connectionsByID[question.ConnectionID]
+			if !ok {
+				continue
+			}
+			answer, answered := answers[questionKey{Type: question.Type, ConnectionID: conn.ID}]
+			// Any card the source reaches through the same relationship is accepted
+			correct := false
+			for _, other := range mindMap.Connections {
+				if answered && answer.TargetID != "" && other.SourceID == conn.SourceID &&
+					strings.EqualFold(other.Relationship, conn.Relationship) && cardsByID[other.TargetID].PublicID == answer.TargetID {
+					correct = true
+				}
+			}
+			grade(question.Type, conn, correct)
+		case quizTypeMissingEdges:
+			answer := answers[questionKey{Type: question.Type}]
+			for _, id := range question.HiddenConnectionIDs {
+				conn, ok := connectionsByID[id]
+				if !ok {
+					continue
+				}
+				correct := false
+				for _, edge := range answer.Edges {
+					if edge.SourceID == cardsByID[conn.SourceID].PublicID && edge.TargetID == cardsByID[conn.TargetID].PublicID &&
+						sameLabel(edge.Relationship, conn.Relationship) {
+						correct = true
+					}
+				}
+				grade(question.Type, conn, correct)
+			}
+		}
+	}
+
+	result := models.MindMapQuizResult{
+		UserID:         user.ID,
+		FlashcardSetID: set.ID,
+		MindMapID:      mindMap.ID,
+		QuizType:       quiz.QuizType,
+		CorrectAnswers: response.Correct,
+		TotalQuestions: response.Total,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Claiming the quiz in the same statement that checks it stops two
+		// concurrent submissions both being recorded
+		claimed := tx.Model(&models.MindMapQuiz{}).Where("id = ? AND graded_at IS NULL", quiz.ID).Update("graded_at", time.Now())
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return errQuizGraded
+		}
+		return tx.Create(&result).Error
+	})
+	if err == errQuizGraded {
+		http.Error(w, "Quiz has already been graded", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to record quiz result", http.StatusInternalServerError)
+		return
+	}
+	response.Result = result
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/quiz/results
+func (db *DBHandler) GetMindMapQuizResults(w http.ResponseWriter, r *http.Request) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+
+	var set models.FlashcardSet
+	if err := db.Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return
+	}
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found in database", http.StatusNotFound)
+		return
+	}
+
+	var results []models.MindMapQuizResult
+	if err := db.Where("mind_map_id = ? AND user_id = ?", mindMap.ID, user.ID).Order("played_at desc").Find(&results).Error; err != nil {
+		http.Error(w, "Failed to fetch quiz results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+// untypedRelationship is the filter value and analytics key for edges with no type.
+const untypedRelationship = "untyped"
+
+// builtinRelationshipTypes is the global vocabulary available in every set.
+var builtinRelationshipTypes = []models.RelationshipType{
+	{
+		Key:         "causes",
+		Name:        "Causes",
+		Category:    models.RelationshipCausal,
+		Directed:    true,
+		Aliases:     "cause,leads to,results in,produces,triggers",
+		Description: "The source brings about the target",
+	},
+	{
+		Key:         "part-of",
+		Name:        "Part of",
+		Category:    models.RelationshipPartOf,
+		Directed:    true,
+		Aliases:     "is part of,component of,belongs to,member of",
+		Description: "The source is a component of the target",
+	},
+	{
+		Key:         "example-of",
+		Name:        "Example of",
+		Category:    models.RelationshipExampleOf,
+		Directed:    true,
+		Aliases:     "is an example of,instance of,is a,such as",
+		Description: "The source is an instance of the target",
+	},
+	{
+		Key:         "contrasts-with",
+		Name:        "Contrasts with",
+		Category:    models.RelationshipContrastsWith,
+		Directed:    false,
+		Aliases:     "versus,vs,opposite of,differs from,compared to",
+		Description: "The source and target are opposed or compared",
+	},
+	{
+		Key:         "related-to",
+		Name:        "Related to",
+		Category:    models.RelationshipRelated,
+		Directed:    false,
+		Aliases:     "related,associated with,see also,similar to,refers to",
+		Description: "Any other association between the source and target",
+	},
+}
+
+func validRelationshipCategory(category string) bool {
+	switch category {
+	case models.RelationshipCausal, models.RelationshipPartOf, models.RelationshipExampleOf,
+		models.RelationshipContrastsWith, models.RelationshipRelated:
+		return true
+	}
+	return false
+}
+
+// relationshipKey turns a type name into its key, e.g. "Leads to" -> "leads-to".
+func relationshipKey(name string) string {
+	return strings.Join(strings.Fields(normalizeTerm(name)), "-")
+}
+
+// relationshipVocabulary returns the built-in types followed by the set's own.
+func relationshipVocabulary(db *gorm.DB, setID uint) ([]models.RelationshipType, error) {
+	var custom []models.RelationshipType
+	if err := db.Where("set_id = ?", setID).Order("id asc").Find(&custom).Error; err != nil {
+		return nil, err
+	}
+	return append(append([]models.RelationshipType{}, builtinRelationshipTypes...), custom...), nil
+}
+
+func findRelationshipType(vocabulary []models.RelationshipType, key string) *models.RelationshipType {
+	for i := range vocabulary {
+		if vocabulary[i].Key == key {
+			return &vocabulary[i]
+		}
+	}
+	return nil
+}
+
+// matchRelationshipType finds the type whose key, name or one of whose aliases
+// matches a free-text label, ignoring case and spacing.
+func matchRelationshipType(vocabulary []models.RelationshipType, label string) *models.RelationshipType {
+	label = normalizeTerm(label)
+	if label == "" {
+		return nil
+	}
+	for i := range vocabulary {
+		if relationshipKey(label) == vocabulary[i].Key || label == normalizeTerm(vocabulary[i].Name) {
+			return &vocabulary[i]
+		}
+		for _, alias := range strings.Split(vocabulary[i].Aliases, ",") {
+			if label == normalizeTerm(alias) {
+				return &vocabulary[i]
+			}
+		}
+	}
+	return nil
+}
+
+// classifyConnection fills in conn's type from its label when it has none and
+// drops a type the vocabulary doesn't know, e.g. after copying to another set.
+func classifyConnection(vocabulary []models.RelationshipType, conn *models.MindMapConnection) {
+	if conn.RelationshipType != "" && findRelationshipType(vocabulary, conn.RelationshipType) == nil {
+		conn.RelationshipType = ""
+	}
+	if conn.RelationshipType == "" {
+		if t := matchRelationshipType(vocabulary, conn.Relationship); t != nil {
+			conn.RelationshipType = t.Key
+		}
+	}
+	if t := findRelationshipType(vocabulary, conn.RelationshipType); t != nil && strings.TrimSpace(conn.Relationship) == "" {
+		conn.Relationship = t.Name
+	}
+}
+
+// typeConnection is classifyConnection for client input, rejecting unknown types.
+func typeConnection(vocabulary []models.RelationshipType, conn *models.MindMapConnection) error {
+	if conn.RelationshipType != "" && findRelationshipType(vocabulary, conn.RelationshipType) == nil {
+		return invalidOp("unknown relationship type %q", conn.RelationshipType)
+	}
+	classifyConnection(vocabulary, conn)
+	return nil
+}
+
+// preloadConnections preloads a mind map's edges, limited to the comma-separated
+// relationship types in ?relationshipType= when present.
+func preloadConnections(query *gorm.DB, r *http.Request) *gorm.DB {
+	if filter := r.URL.Query().Get("relationshipType"); filter != "" {
+		var types []string
+		for _, key := range strings.Split(filter, ",") {
+			key = strings.TrimSpace(key)
+			if key == untypedRelationship {
+				key = ""
+			}
+			types = append(types, key)
+		}
+		query = query.Preload("Connections", "relationship_type IN ?", types)
+	} else {
+		query = query.Preload("Connections")
+	}
+	return query.Preload("Connections.Source").Preload("Connections.Target")
+}
+
+// GET /api/relationship-types
+func (db *DBHandler) GetRelationshipTypes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(builtinRelationshipTypes)
+}
+
+// GET /api/sets/{setID}/relationship-types
+func (db *DBHandler) GetSetRelationshipTypes(w http.ResponseWriter, r *http.Request) {
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", r.PathValue("setID")).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if !set.IsPublic && !db.sharedWith(r, set.ID, 0, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+	vocabulary, err := relationshipVocabulary(db.DB, set.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch relationship types", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vocabulary)
+}
+
+// POST /api/sets/{setID}/relationship-types
+func (db *DBHandler) CreateRelationshipType(w http.ResponseWriter, r *http.Request) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", r.PathValue("setID")).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Name        string   `json:"Name"`
+		Category    string   `json:"Category"`
+		Directed    *bool    `json:"Directed,omitempty"`
+		Aliases     []string `json:"Aliases,omitempty"`
+		Description string   `json:"Description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	key := relationshipKey(req.Name)
+	if key == "" || len(key) > 50 || len(req.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 50 characters", http.StatusBadRequest)
+		return
+	}
+	if !validRelationshipCategory(req.Category) {
+		http.Error(w, "Category must be causal, part-of, example-of, contrasts-with or related", http.StatusBadRequest)
+		return
+	}
+	// Contrasts and loose associations read the same in both directions
+	directed := req.Category != models.RelationshipContrastsWith && req.Category != models.RelationshipRelated
+	if req.Directed != nil {
+		directed = *req.Directed
+	}
+	aliases := strings.Join(req.Aliases, ",")
+	if len(aliases) > 500 || len(req.Description) > 500 {
+		http.Error(w, "Aliases and Description must be at most 500 characters", http.StatusBadRequest)
+		return
+	}
+
+	vocabulary, err := relationshipVocabulary(db.DB, set.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch relationship types", http.StatusInternalServerError)
+		return
+	}
+	if findRelationshipType(vocabulary, key) != nil {
+		http.Error(w, "A relationship type with that name already exists", http.StatusConflict)
+		return
+	}
+
+	relationshipType := models.RelationshipType{
+		SetID:       set.ID,
+		Key:         key,
+		Name:        strings.TrimSpace(req.Name),
+		Category:    req.Category,
+		Directed:    directed,
+		Aliases:     aliases,
+		Description: req.Description,
+	}
+	if err := db.Omit("FlashcardSet").Create(&relationshipType).Error; err != nil {
+		http.Error(w, "Failed to create relationship type", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(relationshipType)
+}
+
+// DELETE /api/sets/{setID}/relationship-types/{typeKey}
+func (db *DBHandler) DeleteRelationshipType(w http.ResponseWriter, r *http.Request) {
+	_, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", r.PathValue("setID")).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var relationshipType models.RelationshipType
+	if err := db.Where("set_id = ? AND key = ?", set.ID, r.PathValue("typeKey")).First(&relationshipType).Error; err != nil {
+		http.Error(w, "Relationship type not found in set", http.StatusNotFound)
+		return
+	}
+	// Edges keep their labels but become untyped
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mindMapIDs := tx.Model(&models.MindMap{}).Select("id").Where("set_id = ?", set.ID)
+		if err := tx.Model(&models.MindMapConnection{}).
+			Where("mind_map_id IN (?) AND relationship_type = ?", mindMapIDs, relationshipType.Key).
+			Update("relationship_type", "").Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&relationshipType).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete relationship type", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+const (
+	snapshotReasonLayouts     = "layouts"
+	snapshotReasonConnections = "connections"
+	snapshotReasonPatch       = "patch"
+	snapshotReasonRestore     = "restore"
+
+	// maxSnapshotsPerMap keeps history from growing forever
+	maxSnapshotsPerMap   = 200
+	defaultMoveThreshold = 50.0
+
+	// Incremental edits are snapshotted at most this often, or after this
+	// many operations, so dragging nodes around doesn't churn through history
+	patchSnapshotInterval = 10 * time.Minute
+	patchSnapshotOps      = 100
+)
+
+// mindMapGraph is the decoded contents of a snapshot.
+type mindMapGraph struct {
+	Connections []models.MindMapConnection
+	Layouts     []models.MindMapNodeLayout
+	Nodes       []models.MindMapNode
+}
+
+func loadMindMapGraph(tx *gorm.DB, mindMapID uint) (mindMapGraph, error) {
+	var graph mindMapGraph
+	if err := tx.Where("mind_map_id = ?", mindMapID).Find(&graph.Connections).Error; err != nil {
+		return graph, err
+	}
+	if err := tx.Where("mind_map_id = ?", mindMapID).Find(&graph.Layouts).Error; err != nil {
+		return graph, err
+	}
+	if err := tx.Where("mind_map_id = ?", mindMapID).Find(&graph.Nodes).Error; err != nil {
+		return graph, err
+	}
+	return graph, nil
+}
+
+func decodeSnapshot(snapshot models.MindMapSnapshot) (mindMapGraph, error) {
+	var graph mindMapGraph
+	if err := json.Unmarshal([]byte(snapshot.Connections), &graph.Connections); err != nil {
+		return graph, err
+	}
+	if err := json.Unmarshal([]byte(snapshot.Layouts), &graph.Layouts); err != nil {
+		return graph, err
+	}
+	if snapshot.Nodes != "" {
+		if err := json.Unmarshal([]byte(snapshot.Nodes), &graph.Nodes); err != nil {
+			return graph, err
+		}
+	}
+	return graph, nil
+}
+
+// snapshotMindMap records the mind map's current graph inside tx and prunes
+// the oldest snapshots beyond maxSnapshotsPerMap.
+func snapshotMindMap(tx *gorm.DB, mindMap *models.MindMap, userID uint, reason string) error {
+	graph, err := loadMindMapGraph(tx, mindMap.ID)
+	if err != nil {
+		return err
+	}
+	connections, _ := json.Marshal(graph.Connections)
+	layouts, _ := json.Marshal(graph.Layouts)
+	nodes, _ := json.Marshal(graph.Nodes)
+	snapshot := models.MindMapSnapshot{
+		MindMapID:   mindMap.ID,
+		UserID:      userID,
+		Seq:         mindMap.Seq,
+		Reason:      reason,
+		Connections: string(connections),
+		Layouts:     string(layouts),
+		Nodes:       string(nodes),
+	}
+	if err := tx.Omit("MindMap").Create(&snapshot).Error; err != nil {
+		return err
+	}
+
+	var stale []uint
+	if err := tx.Model(&models.MindMapSnapshot{}).Where("mind_map_id = ?", mindMap.ID).
+		Order("id desc").Offset(maxSnapshotsPerMap).Pluck("id", &stale).Error; err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return tx.Unscoped().Where("id IN ?", stale).Delete(&models.MindMapSnapshot{}).Error
+	}
+	return nil
+}
+
+// patchSnapshotDue reports whether incremental edits by userID should be
+// snapshotted now: when the map has no snapshot, its last one was another
+// user's, or the last one is older than patchSnapshotInterval or
+// patchSnapshotOps operations behind. Bulk saves and restores always are.
+func patchSnapshotDue(tx *gorm.DB, mindMap *models.MindMap, userID uint) (bool, error) {
+	var last models.MindMapSnapshot
+	err := tx.Where("mind_map_id = ?", mindMap.ID).Order("id desc").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return last.UserID != userID || time.Since(last.CreatedAt) >= patchSnapshotInterval ||
+		mindMap.Seq-last.Seq >= patchSnapshotOps, nil
+}
+
+// loadReadableMindMap resolves the set and mind map in the path for readers,
+// allowing public maps and the set owner.
+func (db *DBHandler) loadReadableMindMap(w http.ResponseWriter, r *http.Request) (*models.MindMap, bool) {
+	setID := r.PathValue("setID")
+	mindMapID := r.PathValue("mindMapID")
+	if setID == "" || mindMapID == "" {
+		http.Error(w, "Set ID and MindMap ID are required", http.StatusBadRequest)
+		return nil, false
+	}
+	var set models.FlashcardSet
+	if err := db.Preload("User").Where("public_id = ?", setID).First(&set).Error; err != nil {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return nil, false
+	}
+	var mindMap models.MindMap
+	if err := db.Where("public_id = ? AND set_id = ?", mindMapID, set.ID).First(&mindMap).Error; err != nil {
+		http.Error(w, "MindMap not found in set", http.StatusNotFound)
+		return nil, false
+	}
+	if !mindMap.IsPublic && !db.sharedWith(r, set.ID, mindMap.ID, models.ShareScopeView) {
+		if !db.hasSetRole(r, &set, models.OrgViewer) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return &mindMap, true
+}
+
+func (db *DBHandler) findSnapshot(w http.ResponseWriter, mindMap *models.MindMap, rawID string) (models.MindMapSnapshot, bool) {
+	var snapshot models.MindMapSnapshot
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid snapshot ID %q", rawID), http.StatusBadRequest)
+		return snapshot, false
+	}
+	if err := db.Where("id = ? AND mind_map_id = ?", id, mindMap.ID).First(&snapshot).Error; err != nil {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return snapshot, false
+	}
+	return snapshot, true
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots
+func (db *DBHandler) GetMindMapSnapshots(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadReadableMindMap(w, r)
+	if !ok {
+		return
+	}
+	var snapshots []models.MindMapSnapshot
+	if err := db.Where("mind_map_id = ?", mindMap.ID).Order("id desc").Find(&snapshots).Error; err != nil {
+		http.Error(w, "Failed to fetch snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	type SnapshotSummary struct {
+		ID              uint
+		Seq             uint
+		Reason          string
+		CreatedAt       time.Time
+		ConnectionCount int
+		NodeCount       int
+	}
+	summaries := make([]SnapshotSummary, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		graph, err := decodeSnapshot(snapshot)
+		if err != nil {
+			http.Error(w, "Failed to decode snapshot", http.StatusInternalServerError)
+			return
+		}
+		summaries = append(summaries, SnapshotSummary{
+			ID:              snapshot.ID,
+			Seq:             snapshot.Seq,
+			Reason:          snapshot.Reason,
+			CreatedAt:       snapshot.CreatedAt,
+			ConnectionCount: len(graph.Connections),
+			NodeCount:       len(graph.Layouts) + len(graph.Nodes),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/{snapshotID}
+func (db *DBHandler) GetMindMapSnapshot(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadReadableMindMap(w, r)
+	if !ok {
+		return
+	}
+	snapshot, ok := db.findSnapshot(w, mindMap, r.PathValue("snapshotID"))
+	if !ok {
+		return
+	}
+	graph, err := decodeSnapshot(snapshot)
+	if err != nil {
+		http.Error(w, "Failed to decode snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	type SnapshotResponse struct {
+		models.MindMapSnapshot
+		Connections []models.MindMapConnection
+		NodeLayouts []models.MindMapNodeLayout `json:"nodeLayouts"`
+		Nodes       []models.MindMapNode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SnapshotResponse{
+		MindMapSnapshot: snapshot,
+		Connections:     graph.Connections,
+		NodeLayouts:     graph.Layouts,
+		Nodes:           graph.Nodes,
+	})
+}
+
+// POST /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/{snapshotID}/restore
+func (db *DBHandler) RestoreMindMapSnapshot(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadOwnedMindMap(w, r)
+	if !ok {
+		return
+	}
+	snapshot, ok := db.findSnapshot(w, mindMap, r.PathValue("snapshotID"))
+	if !ok {
+		return
+	}
+	graph, err := decodeSnapshot(snapshot)
+	if err != nil {
+		http.Error(w, "Failed to decode snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var ops []mindMapOp
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMindMap(tx, mindMap); err != nil {
+			return err
+		}
+		if err := tx.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapConnection{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapNodeLayout{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("mind_map_id = ?", mindMap.ID).Delete(&models.MindMapNode{}).Error; err != nil {
+			return err
+		}
+		// Flashcards deleted since the snapshot can't be restored onto the map
+		var cardIDs []uint
+		if err := tx.Model(&models.Flashcard{}).Where("set_id = ?", mindMap.SetID).Pluck("id", &cardIDs).Error; err != nil {
+			return err
+		}
+		exists := make(map[uint]bool, len(cardIDs))
+		for _, id := range cardIDs {
+			exists[id] = true
+		}
+		var connections []models.MindMapConnection
+		for _, conn := range graph.Connections {
+			if (conn.SourceNodeID != nil || exists[conn.SourceID]) && (conn.TargetNodeID != nil || exists[conn.TargetID]) {
+				connections = append(connections, conn)
+			}
+		}
+		var layouts []models.MindMapNodeLayout
+		for _, layout := range graph.Layouts {
+			if exists[layout.FlashcardID] {
+				layouts = append(layouts, layout)
+			}
+		}
+		if err := insertMindMapGraph(tx, mindMap, graph.Nodes, connections, layouts); err != nil {
+			return err
+		}
+
+		// Nodes come back with new IDs, which the edges and layouts now use
+		edges, _ := json.Marshal(connections)
+		nodes, _ := json.Marshal(layouts)
+		mapNodes, _ := json.Marshal(graph.Nodes)
+		ops = []mindMapOp{
+			{Op: opReplace, Path: "/mapnodes", Value: mapNodes},
+			{Op: opReplace, Path: "/edges", Value: edges},
+			{Op: opReplace, Path: "/nodes", Value: nodes},
+		}
+		for i := range ops {
+			if err := recordMindMapOp(tx, mindMap, user.ID, "", &ops[i]); err != nil {
+				return err
+			}
+		}
+		return snapshotMindMap(tx, mindMap, user.ID, snapshotReasonRestore)
+	})
+	if err != nil {
+		http.Error(w, "Failed to restore snapshot", http.StatusInternalServerError)
+		return
+	}
+	mindMapEvents.publishOps(mindMap.ID, ops)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/diff?from=&to=&threshold=
+// Omitting to compares against the map's current state.
+func (db *DBHandler) DiffMindMapSnapshots(w http.ResponseWriter, r *http.Request) {
+	mindMap, ok := db.loadReadableMindMap(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	threshold := defaultMoveThreshold
+	if raw := query.Get("threshold"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "threshold must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	fromSnapshot, ok := db.findSnapshot(w, mindMap, query.Get("from"))
+	if !ok {
+		return
+	}
+	from, err := decodeSnapshot(fromSnapshot)
+	if err != nil {
+		http.Error(w, "Failed to decode snapshot", http.StatusInternalServerError)
+		return
+	}
+	var to mindMapGraph
+	if raw := query.Get("to"); raw != "" {
+		toSnapshot, ok := db.findSnapshot(w, mindMap, raw)
+		if !ok {
+			return
+		}
+		if to, err = decodeSnapshot(toSnapshot); err != nil {
+			http.Error(w, "Failed to decode snapshot", http.StatusInternalServerError)
+			return
+		}
+	} else if to, err = loadMindMapGraph(db.DB, mindMap.ID); err != nil {
+		http.Error(w, "Failed to load mind map", http.StatusInternalServerError)
+		return
+	}
+
+	type EdgeChange struct {
+		SourceID         uint  `json:",omitempty"`
+		TargetID         uint  `json:",omitempty"`
+		SourceNodeID     *uint `json:",omitempty"`
+		TargetNodeID     *uint `json:",omitempty"`
+		Relationship     string
+		FromRelationship string `json:",omitempty"`
+	}
+	type NodeMove struct {
+		FlashcardID uint
+		FromX       float64
+		FromY       float64
+		ToX         float64
+		ToY         float64
+		Distance    float64
+	}
+	type DiffResponse struct {
+		EdgesAdded    []EdgeChange
+		EdgesRemoved  []EdgeChange
+		Relabelled    []EdgeChange
+		NodesAdded    []uint
+		NodesRemoved  []uint
+		NodesMoved    []NodeMove
+		MoveThreshold float64
+	}
+	response := DiffResponse{
+		EdgesAdded:    []EdgeChange{},
+		EdgesRemoved:  []EdgeChange{},
+		Relabelled:    []EdgeChange{},
+		NodesAdded:    []uint{},
+		NodesRemoved:  []uint{},
+		NodesMoved:    []NodeMove{},
+		MoveThreshold: threshold,
+	}
+
+	// Connection IDs change on every bulk save, so edges are matched by their endpoints
+	edgeKey := func(conn models.MindMapConnection) string {
+		end := func(cardID uint, nodeID *uint) string {
+			if nodeID != nil {
+				return fmt.Sprintf("n%d", *nodeID)
+			}
+			return fmt.Sprintf("c%d", cardID)
+		}
+		return end(conn.SourceID, conn.SourceNodeID) + ">" + end(conn.TargetID, conn.TargetNodeID)
+	}
+	change := func(conn models.MindMapConnection) EdgeChange {
+		return EdgeChange{
+			SourceID:     conn.SourceID,
+			TargetID:     conn.TargetID,
+			SourceNodeID: conn.SourceNodeID,
+			TargetNodeID: conn.TargetNodeID,
+			Relationship: conn.Relationship,
+		}
+	}
+	fromEdges := make(map[string]models.MindMapConnection, len(from.Connections))
+	for _, conn := range from.Connections {
+		fromEdges[edgeKey(conn)] = conn
+	}
+	toEdges := make(map[string]bool, len(to.Connections))
+	for _, conn := range to.Connections {
+		key := edgeKey(conn)
+		toEdges[key] = true
+		previous, existed := fromEdges[key]
+		switch {
+		case !existed:
+			response.EdgesAdded = append(response.EdgesAdded, change(conn))
+		case previous.Relationship != conn.Relationship:
+			relabelled := change(conn)
+			relabelled.FromRelationship = previous.Relationship
+			response.Relabelled = append(response.Relabelled, relabelled)
+		}
+	}
+	for _, conn := range from.Connections {
+		if !toEdges[edgeKey(conn)] {
+			response.EdgesRemoved = append(response.EdgesRemoved, change(conn))
+		}
+	}
+
+	fromLayouts := make(map[uint]models.MindMapNodeLayout, len(from.Layouts))
+	for _, layout := range from.Layouts {
+		fromLayouts[layout.FlashcardID] = layout
+	}
+	toLayouts := make(map[uint]bool, len(to.Layouts))
+	for _, layout := range to.Layouts {
+		toLayouts[layout.FlashcardID] = true
+		previous, existed := fromLayouts[layout.FlashcardID]
+		if !existed {
+			response.NodesAdded = append(response.NodesAdded, layout.FlashcardID)
+			continue
+		}
+		distance := math.Hypot(layout.XPosition-previous.XPosition, layout.YPosition-previous.YPosition)
+		if distance > threshold {
+			response.NodesMoved = append(response.NodesMoved, NodeMove{
+				FlashcardID: layout.FlashcardID,
+				FromX:       previous.XPosition,
+				FromY:       previous.YPosition,
+				ToX:         layout.XPosition,
+				ToY:         layout.YPosition,
+				Distance:    distance,
+			})
+		}
+	}
+	for _, layout := range from.Layouts {
+		if !toLayouts[layout.FlashcardID] {
+			response.NodesRemoved = append(response.NodesRemoved, layout.FlashcardID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
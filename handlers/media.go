@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/storage"
+)
+
+const (
+	maxImageBytes    = 5 << 20
+	maxImagePixels   = 40_000_000 // A compressed image this large still decodes to 160 MB
+	maxAudioBytes    = 20 << 20
+	maxMediaPerCard  = 10
+	thumbnailSize    = 256
+	mediaURLLifetime = 15 * time.Minute
+
+	mediaVariantOriginal  = "original"
+	mediaVariantThumbnail = "thumbnail"
+)
+
+// mediaTypes maps the sniffed content type of an upload to its kind and the
+// content type it's served with. Anything else is rejected.
+var mediaTypes = map[string]struct{ kind, contentType string }{
+	"image/png":       {models.MediaImage, "image/png"},
+	"image/jpeg":      {models.MediaImage, "image/jpeg"},
+	"image/gif":       {models.MediaImage, "image/gif"},
+	"image/webp":      {models.MediaImage, "image/webp"},
+	"audio/mpeg":      {models.MediaAudio, "audio/mpeg"},
+	"audio/wave":      {models.MediaAudio, "audio/wav"},
+	"application/ogg": {models.MediaAudio, "audio/ogg"},
+}
+
+type mediaResponse struct {
+	models.FlashcardMedia
+	URL          string
+	ThumbnailURL string `json:",omitempty"`
+}
+
+// makeThumbnail scales an image down to fit a thumbnailSize square, averaging
+// the source pixels under each thumbnail pixel. It returns the PNG thumbnail
+// and the original dimensions.
+func makeThumbnail(data []byte) ([]byte, int, int, error) {
+	// Check the dimensions before decoding, since a small file can be huge in pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, 0, 0, errors.New("image is too large to thumbnail")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, 0, 0, errors.New("empty image")
+	}
+	scale := min(1, float64(thumbnailSize)/float64(max(width, height)))
+	tw, th := max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*height/th, bounds.Min.Y+max((y+1)*height/th, y*height/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*width/tw, bounds.Min.X+max((x+1)*width/tw, x*width/tw+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			i := thumb.PixOffset(x, y)
+			thumb.Pix[i] = uint8(r / n >> 8)
+			thumb.Pix[i+1] = uint8(g / n >> 8)
+			thumb.Pix[i+2] = uint8(b / n >> 8)
+			thumb.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, thumb); err != nil {
+		return nil, 0, 0, err
+	}
+	return out.Bytes(), width, height, nil
+}
+
+func (db *DBHandler) mediaSignature(publicID, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, db.MediaURLSecret)
+	fmt.Fprintf(mac, "%s|%s|%d", publicID, variant, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// mediaURL returns a link to a media variant that works without credentials
+// until it expires, so it can be used directly in <img> and <audio> tags.
+func (db *DBHandler) mediaURL(publicID, variant string) string {
+	expires := time.Now().Add(mediaURLLifetime).Unix()
+	return fmt.Sprintf("/api/media/%s?variant=%s&expires=%d&sig=%s",
+		publicID, variant, expires, db.mediaSignature(publicID, variant, expires))
+}
+
+func (db *DBHandler) newMediaResponse(media models.FlashcardMedia) mediaResponse {
+	response := mediaResponse{FlashcardMedia: media, URL: db.mediaURL(media.PublicID, mediaVariantOriginal)}
+	if media.ThumbnailKey != "" {
+		response.ThumbnailURL = db.mediaURL(media.PublicID, mediaVariantThumbnail)
+	}
+	return response
+}
+
+// deleteMediaBlobs removes stored files for media whose rows are already gone.
+// Failures are logged rather than returned since the rows can't be restored.
+func (db *DBHandler) deleteMediaBlobs(ctx context.Context, media []models.FlashcardMedia) {
+	for _, m := range media {
+		for _, key := range []string{m.StorageKey, m.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := db.Storage.Delete(ctx, key); err != nil {
+				log.Printf("deleteMediaBlobs: Failed to delete %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// deleteCardMedia removes the attachments of the cards selected by cardIDs,
+// rows and stored files both. Cards are soft-deleted, so the database's
+// cascade never removes their media on its own.
+func (db *DBHandler) deleteCardMedia(ctx context.Context, cardIDs *gorm.DB) error {
+	var media []models.FlashcardMedia
+	if err := db.Where("flashcard_id IN (?)", cardIDs).Find(&media).Error; err != nil {
+		return err
+	}
+	if len(media) == 0 {
+		return nil
+	}
+	if err := db.Unscoped().Delete(&media).Error; err != nil {
+		return err
+	}
+	db.deleteMediaBlobs(ctx, media)
+	return nil
+}
+
+// GET /api/sets/{setID}/flashcards/{flashcardID}/media
+func (db *DBHandler) GetFlashcardMedia(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadReadableSet(w, r)
+	if !ok {
+		return
+	}
+	var flashcard models.Flashcard
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("flashcardID"), set.ID).First(&flashcard).Error; err != nil {
+		http.Error(w, "Flashcard not found", http.StatusNotFound)
+		return
+	}
+	var media []models.FlashcardMedia
+	if err := db.Where("flashcard_id = ?", flashcard.ID).Order("id asc").Find(&media).Error; err != nil {
+		http.Error(w, "Failed to fetch media", http.StatusInternalServerError)
+		return
+	}
+	response := make([]mediaResponse, 0, len(media))
+	for _, m := range media {
+		response = append(response, db.newMediaResponse(m))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /api/sets/{setID}/flashcards/{flashcardID}/media
+//
+// Takes a multipart form with the upload in "file" and "side" set to term or solution.
+func (db *DBHandler) UploadFlashcardMedia(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var flashcard models.Flashcard
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("flashcardID"), set.ID).First(&flashcard).Error; err != nil {
+		http.Error(w, "Flashcard not found", http.StatusNotFound)
+		return
+	}
+	var count int64
+	if err := db.Model(&models.FlashcardMedia{}).Where("flashcard_id = ?", flashcard.ID).Count(&count).Error; err != nil {
+		http.Error(w, "Failed to count media", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxMediaPerCard {
+		http.Error(w, fmt.Sprintf("A flashcard can have at most %d attachments", maxMediaPerCard), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAudioBytes+(1<<20))
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, "Upload is too large or not a multipart form", http.StatusRequestEntityTooLarge)
+		return
+	}
+	side := r.FormValue("side")
+	if side == "" {
+		side = models.MediaSideTerm
+	}
+	if side != models.MediaSideTerm && side != models.MediaSideSolution {
+		http.Error(w, "side must be term or solution", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxAudioBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type
+	sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	mediaType, ok := mediaTypes[sniffed]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unsupported media type %s", sniffed), http.StatusUnsupportedMediaType)
+		return
+	}
+	limit := maxAudioBytes
+	if mediaType.kind == models.MediaImage {
+		limit = maxImageBytes
+	}
+	if len(data) > limit {
+		http.Error(w, fmt.Sprintf("%s uploads must be at most %d MB", mediaType.kind, limit>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if mediaType.kind == models.MediaImage {
+		// Formats the standard library can't read, such as WebP, aren't checked
+		if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && config.Width*config.Height > maxImagePixels {
+			http.Error(w, fmt.Sprintf("Images must be at most %d megapixels", maxImagePixels/1_000_000), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	media := models.FlashcardMedia{
+		PublicID:    publicID,
+		FlashcardID: flashcard.ID,
+		Side:        side,
+		Kind:        mediaType.kind,
+		ContentType: mediaType.contentType,
+		Size:        int64(len(data)),
+		StorageKey:  "media/" + publicID + "/" + mediaVariantOriginal,
+	}
+	if err := db.Storage.Put(r.Context(), media.StorageKey, bytes.NewReader(data), media.Size, media.ContentType); err != nil {
+		log.Printf("UploadFlashcardMedia: Failed to store %s: %v", media.StorageKey, err)
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	if media.Kind == models.MediaImage {
+		// Formats the standard library can't decode, such as WebP, go without a thumbnail
+		if thumb, width, height, err := makeThumbnail(data); err == nil {
+			media.Width, media.Height = width, height
+			key := "media/" + publicID + "/" + mediaVariantThumbnail
+			if err := db.Storage.Put(r.Context(), key, bytes.NewReader(thumb), int64(len(thumb)), "image/png"); err == nil {
+				media.ThumbnailKey = key
+			}
+		}
+	}
+	if err := db.Omit("Flashcard").Create(&media).Error; err != nil {
+		db.deleteMediaBlobs(r.Context(), []models.FlashcardMedia{media})
+		http.Error(w, "Failed to save media", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(db.newMediaResponse(media))
+}
+
+// DELETE /api/sets/{setID}/flashcards/{flashcardID}/media/{mediaID}
+func (db *DBHandler) DeleteFlashcardMedia(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var flashcard models.Flashcard
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("flashcardID"), set.ID).First(&flashcard).Error; err != nil {
+		http.Error(w, "Flashcard not found", http.StatusNotFound)
+		return
+	}
+	var media models.FlashcardMedia
+	if err := db.Where("public_id = ? AND flashcard_id = ?", r.PathValue("mediaID"), flashcard.ID).First(&media).Error; err != nil {
+		http.Error(w, "Media not found on flashcard", http.StatusNotFound)
+		return
+	}
+	if err := db.Unscoped().Delete(&media).Error; err != nil {
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+	db.deleteMediaBlobs(r.Context(), []models.FlashcardMedia{media})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/media/{mediaID}?variant=&expires=&sig=
+//
+// Serves a file from a signed link handed out by the media endpoints above.
+func (db *DBHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	publicID := r.PathValue("mediaID")
+	query := r.URL.Query()
+	variant := query.Get("variant")
+	if variant == "" {
+		variant = mediaVariantOriginal
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		http.Error(w, "Link has expired", http.StatusForbidden)
+		return
+	}
+	expected := db.mediaSignature(publicID, variant, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var media models.FlashcardMedia
+	if err := db.Where("public_id = ?", publicID).First(&media).Error; err != nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	key, contentType := media.StorageKey, media.ContentType
+	if variant == mediaVariantThumbnail {
+		if media.ThumbnailKey == "" {
+			http.Error(w, "Media has no thumbnail", http.StatusNotFound)
+			return
+		}
+		key, contentType = media.ThumbnailKey, "image/png"
+	} else if variant != mediaVariantOriginal {
+		http.Error(w, "variant must be original or thumbnail", http.StatusBadRequest)
+		return
+	}
+
+	body, err := db.Storage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ServeMedia: Failed to load %s: %v", key, err)
+		http.Error(w, "Failed to load media", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(mediaURLLifetime.Seconds())))
+	io.Copy(w, body)
+}
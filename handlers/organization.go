@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const maxOrgSearchResults = 50
+
+// tenantScope limits a query on sets or mind maps to one tenant: an
+// organization, or personal content outside any organization when orgID is nil.
+func tenantScope(orgID *uint) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if orgID == nil {
+			return query.Where("organization_id IS NULL")
+		}
+		return query.Where("organization_id = ?", *orgID)
+	}
+}
+
+// sameTenant reports whether two sets belong to the same organization, or both to none.
+func sameTenant(a, b *models.FlashcardSet) bool {
+	if a.OrganizationID == nil || b.OrganizationID == nil {
+		return a.OrganizationID == nil && b.OrganizationID == nil
+	}
+	return *a.OrganizationID == *b.OrganizationID
+}
+
+// orgRole returns userID's role in the organization, or "" for non-members.
+func orgRole(db *gorm.DB, orgID, userID uint) string {
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// hasSetRole reports whether the caller holds at least role on the set. The
+// creator of a personal set holds every role; on an organization's set only
+// their organization role counts.
+func (db *DBHandler) hasSetRole(r *http.Request, set *models.FlashcardSet, role string) bool {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		return false
+	}
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		return false
+	}
+	if set.OrganizationID == nil {
+		return set.UserID == user.ID
+	}
+	return models.OrgRoleRank(orgRole(db.DB, *set.OrganizationID, user.ID)) >= models.OrgRoleRank(role)
+}
+
+// loadOrganization resolves the organization in the path for members holding
+// at least role. Non-members get a 404 so organizations can't be discovered by ID.
+func (db *DBHandler) loadOrganization(w http.ResponseWriter, r *http.Request, role string) (*models.Organization, *models.OrganizationMember, bool) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	var org models.Organization
+	if err := db.Where("public_id = ?", r.PathValue("orgID")).First(&org).Error; err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&member).Error; err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if models.OrgRoleRank(member.Role) < models.OrgRoleRank(role) {
+		http.Error(w, "Your role in this organization doesn't allow that", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return &org, &member, true
+}
+
+type organizationResponse struct {
+	models.Organization
+	Role string
+}
+
+// GET /api/orgs
+func (db *DBHandler) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var members []models.OrganizationMember
+	if err := db.Preload("Organization").Where("user_id = ?", user.ID).Order("id asc").Find(&members).Error; err != nil {
+		http.Error(w, "Failed to fetch organizations", http.StatusInternalServerError)
+		return
+	}
+	response := make([]organizationResponse, 0, len(members))
+	for _, m := range members {
+		response = append(response, organizationResponse{Organization: m.Organization, Role: m.Role})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /api/orgs
+func (db *DBHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	org := models.Organization{PublicID: publicID, Name: req.Name}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Organization", "User").Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           models.OrgOwner,
+		}).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(organizationResponse{Organization: org, Role: models.OrgOwner})
+}
+
+// GET /api/orgs/{orgID}
+func (db *DBHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, member, ok := db.loadOrganization(w, r, models.OrgViewer)
+	if !ok {
+		return
+	}
+	var members []models.OrganizationMember
+	if err := db.Preload("User").Where("organization_id = ?", org.ID).Order("id asc").Find(&members).Error; err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	type Member struct {
+		Nickname string
+		Role     string
+	}
+	type OrganizationDetail struct {
+		organizationResponse
+		Members []Member
+	}
+	detail := OrganizationDetail{
+		organizationResponse: organizationResponse{Organization: *org, Role: member.Role},
+		Members:              make([]Member, 0, len(members)),
+	}
+	for _, m := range members {
+		detail.Members = append(detail.Members, Member{Nickname: m.User.Nickname, Role: m.Role})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// PUT /api/orgs/{orgID}
+func (db *DBHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	org, member, ok := db.loadOrganization(w, r, models.OrgOwner)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "Name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if err := db.Model(org).Update("name", req.Name).Error; err != nil {
+		http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(organizationResponse{Organization: *org, Role: member.Role})
+}
+
+// DELETE /api/orgs/{orgID}
+//
+// Only empty organizations can be deleted, so a shared library is never lost
+// by accident; its sets have to be deleted first.
+func (db *DBHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := db.loadOrganization(w, r, models.OrgOwner)
+	if !ok {
+		return
+	}
+	var sets int64
+	if err := db.Model(&models.FlashcardSet{}).Scopes(tenantScope(&org.ID)).Count(&sets).Error; err != nil {
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	if sets > 0 {
+		http.Error(w, "Delete the organization's sets first", http.StatusConflict)
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("organization_id = ?", org.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/orgs/{orgID}/members/{nickname}
+//
+// Adds a user to the organization or changes their role. Admins manage
+// viewers, editors and other admins; only owners can grant or take away
+// ownership.
+func (db *DBHandler) SetOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	org, member, ok := db.loadOrganization(w, r, models.OrgAdmin)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role string `json:"Role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if models.OrgRoleRank(req.Role) == 0 {
+		http.Error(w, "Role must be viewer, editor, admin or owner", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := db.Where("nickname = ?", r.PathValue("nickname")).First(&user).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	current := orgRole(db.DB, org.ID, user.ID)
+	if (req.Role == models.OrgOwner || current == models.OrgOwner) && member.Role != models.OrgOwner {
+		http.Error(w, "Only owners can change ownership", http.StatusForbidden)
+		return
+	}
+	if current == models.OrgOwner && req.Role != models.OrgOwner && db.lastOrgOwner(org.ID) {
+		http.Error(w, "An organization needs at least one owner", http.StatusBadRequest)
+		return
+	}
+
+	var updated models.OrganizationMember
+	err := db.Omit("Organization", "User").Where("organization_id = ? AND user_id = ?", org.ID, user.ID).
+		Assign(models.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, Role: req.Role}).
+		FirstOrCreate(&updated).Error
+	if err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/orgs/{orgID}/members/{nickname}
+//
+// Admins can remove members below owner; anyone can remove themselves to
+// leave, except the last owner.
+func (db *DBHandler) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	org, member, ok := db.loadOrganization(w, r, models.OrgViewer)
+	if !ok {
+		return
+	}
+	var user models.User
+	var target models.OrganizationMember
+	if err := db.Where("nickname = ?", r.PathValue("nickname")).First(&user).Error; err != nil ||
+		db.Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&target).Error != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	self := target.UserID == member.UserID
+	canManage := models.OrgRoleRank(member.Role) >= models.OrgRoleRank(models.OrgAdmin) &&
+		(target.Role != models.OrgOwner || member.Role == models.OrgOwner)
+	if !self && !canManage {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if target.Role == models.OrgOwner && db.lastOrgOwner(org.ID) {
+		http.Error(w, "An organization needs at least one owner", http.StatusBadRequest)
+		return
+	}
+	if err := db.Unscoped().Delete(&target).Error; err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (db *DBHandler) lastOrgOwner(orgID uint) bool {
+	var owners int64
+	db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", orgID, models.OrgOwner).Count(&owners)
+	return owners <= 1
+}
+
+// GET /api/orgs/{orgID}/sets?q=
+func (db *DBHandler) GetOrganizationSets(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := db.loadOrganization(w, r, models.OrgViewer)
+	if !ok {
+		return
+	}
+	query := db.Scopes(tenantScope(&org.ID)).Preload("Flashcards", orderFlashcards)
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		query = query.Where("title ILIKE ?", "%"+escapeLike(q)+"%")
+	}
+	var sets []models.FlashcardSet
+	if err := query.Order("title asc").Find(&sets).Error; err != nil {
+		http.Error(w, "Failed to fetch sets", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sets)
+}
+
+// GET /api/orgs/{orgID}/mindmaps?q=
+func (db *DBHandler) GetOrganizationMindMaps(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := db.loadOrganization(w, r, models.OrgViewer)
+	if !ok {
+		return
+	}
+	query := db.Scopes(tenantScope(&org.ID))
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		query = query.Where("title ILIKE ?", "%"+escapeLike(q)+"%")
+	}
+	var mindMaps []models.MindMap
+	if err := query.Order("title asc").Find(&mindMaps).Error; err != nil {
+		http.Error(w, "Failed to fetch mind maps", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mindMaps)
+}
+
+// GET /api/orgs/{orgID}/search?q=
+//
+// Finds sets by title and flashcards by term or solution, only ever within
+// the organization's own library.
+func (db *DBHandler) SearchOrganization(w http.ResponseWriter, r *http.Request) {
+	org, _, ok := db.loadOrganization(w, r, models.OrgViewer)
+	if !ok {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	pattern := "%" + escapeLike(q) + "%"
+
+	var sets []models.FlashcardSet
+	if err := db.Scopes(tenantScope(&org.ID)).Where("title ILIKE ?", pattern).
+		Order("title asc").Limit(maxOrgSearchResults).Find(&sets).Error; err != nil {
+		http.Error(w, "Failed to search sets", http.StatusInternalServerError)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := db.Preload("FlashcardSet").
+		Joins("JOIN flashcard_sets ON flashcard_sets.id = flashcards.set_id AND flashcard_sets.deleted_at IS NULL").
+		Where("flashcard_sets.organization_id = ?", org.ID).
+		Where("flashcards.term ILIKE ? OR flashcards.solution ILIKE ?", pattern, pattern).
+		Order("flashcards.id asc").Limit(maxOrgSearchResults).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to search flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	type FlashcardMatch struct {
+		models.Flashcard
+		Set      string // Public ID
+		SetTitle string
+	}
+	type SearchResults struct {
+		Sets       []models.FlashcardSet
+		Flashcards []FlashcardMatch
+	}
+	results := SearchResults{Sets: sets, Flashcards: make([]FlashcardMatch, 0, len(flashcards))}
+	for _, fc := range flashcards {
+		results.Flashcards = append(results.Flashcards, FlashcardMatch{
+			Flashcard: fc,
+			Set:       fc.FlashcardSet.PublicID,
+			SetTitle:  fc.FlashcardSet.Title,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// escapeLike escapes LIKE wildcards so user input only matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zones resolve even on hosts without a zoneinfo database
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+const (
+	maxDailyNewCards   = 500
+	maxDailyReviewGoal = 5000
+)
+
+var nicknamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,30}$`)
+
+type profileResponse struct {
+	Nickname        string
+	DisplayName     string
+	AvatarURL       string
+	Bio             string
+	TimeZone        string
+	DailyNewCards   int
+	DailyReviewGoal int
+	Scheduler       string
+	PublicByDefault bool
+	CreatedAt       time.Time
+
+	DeletionScheduledFor *time.Time `json:",omitempty"` // Set while the account is waiting to be deleted
+}
+
+func newProfileResponse(user *models.User) profileResponse {
+	return profileResponse{
+		Nickname:        user.Nickname,
+		DisplayName:     user.DisplayName,
+		AvatarURL:       user.AvatarURL,
+		Bio:             user.Bio,
+		TimeZone:        user.TimeZone,
+		DailyNewCards:   user.DailyNewCards,
+		DailyReviewGoal: user.DailyReviewGoal,
+		Scheduler:       user.Scheduler,
+		PublicByDefault: user.PublicByDefault,
+		CreatedAt:       user.CreatedAt,
+
+		DeletionScheduledFor: user.DeletionScheduledAt,
+	}
+}
+
+// GET /api/me
+func (db *DBHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newProfileResponse(user))
+}
+
+// PATCH /api/me
+//
+// Only the fields present in the body change.
+func (db *DBHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Nickname        *string `json:"Nickname"`
+		DisplayName     *string `json:"DisplayName"`
+		AvatarURL       *string `json:"AvatarURL"`
+		Bio             *string `json:"Bio"`
+		TimeZone        *string `json:"TimeZone"`
+		DailyNewCards   *int    `json:"DailyNewCards"`
+		DailyReviewGoal *int    `json:"DailyReviewGoal"`
+		Scheduler       *string `json:"Scheduler"`
+		PublicByDefault *bool   `json:"PublicByDefault"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updates := make(map[string]any)
+	if req.Nickname != nil && *req.Nickname != user.Nickname {
+		if !nicknamePattern.MatchString(*req.Nickname) {
+			http.Error(w, "Nickname must be 3-30 letters, digits, dots, dashes or underscores", http.StatusBadRequest)
+			return
+		}
+		// Nicknames appear in URLs, so ones differing only in case would be confusing
+		var taken int64
+		if err := db.Model(&models.User{}).Where("LOWER(nickname) = LOWER(?) AND id != ?", *req.Nickname, user.ID).Count(&taken).Error; err != nil {
+			http.Error(w, "Failed to check nickname", http.StatusInternalServerError)
+			return
+		}
+		if taken > 0 {
+			http.Error(w, "That nickname is already taken", http.StatusConflict)
+			return
+		}
+		updates["nickname"] = *req.Nickname
+		updates["nickname_customized"] = true
+	}
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len(name) > 100 {
+			http.Error(w, "DisplayName must be at most 100 characters", http.StatusBadRequest)
+			return
+		}
+		updates["display_name"] = name
+	}
+	if req.AvatarURL != nil {
+		if *req.AvatarURL != "" {
+			parsed, err := url.Parse(*req.AvatarURL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" || len(*req.AvatarURL) > 500 {
+				http.Error(w, "AvatarURL must be an http or https URL of at most 500 characters", http.StatusBadRequest)
+				return
+			}
+		}
+		updates["avatar_url"] = *req.AvatarURL
+	}
+	if req.Bio != nil {
+		if len(*req.Bio) > 1000 {
+			http.Error(w, "Bio must be at most 1000 characters", http.StatusBadRequest)
+			return
+		}
+		updates["bio"] = *req.Bio
+	}
+	if req.TimeZone != nil {
+		// LoadLocation treats "" as UTC and "Local" as the server's zone
+		if _, err := time.LoadLocation(*req.TimeZone); err != nil || *req.TimeZone == "" || *req.TimeZone == "Local" {
+			http.Error(w, "TimeZone must be an IANA time zone such as Europe/London", http.StatusBadRequest)
+			return
+		}
+		updates["time_zone"] = *req.TimeZone
+	}
+	if req.DailyNewCards != nil {
+		if *req.DailyNewCards < 0 || *req.DailyNewCards > maxDailyNewCards {
+			http.Error(w, "DailyNewCards must be between 0 and 500", http.StatusBadRequest)
+			return
+		}
+		updates["daily_new_cards"] = *req.DailyNewCards
+	}
+	if req.DailyReviewGoal != nil {
+		if *req.DailyReviewGoal < 0 || *req.DailyReviewGoal > maxDailyReviewGoal {
+			http.Error(w, "DailyReviewGoal must be between 0 and 5000", http.StatusBadRequest)
+			return
+		}
+		updates["daily_review_goal"] = *req.DailyReviewGoal
+	}
+	if req.Scheduler != nil {
+		if *req.Scheduler != models.SchedulerSM2 && *req.Scheduler != models.SchedulerLeitner {
+			http.Error(w, "Scheduler must be sm2 or leitner", http.StatusBadRequest)
+			return
+		}
+		updates["scheduler"] = *req.Scheduler
+	}
+	if req.PublicByDefault != nil {
+		updates["public_by_default"] = *req.PublicByDefault
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(user).Updates(updates).Error; err != nil {
+			// Another account can claim the nickname between the check and the update
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				http.Error(w, "That nickname is already taken", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+		if err := db.First(user, user.ID).Error; err != nil {
+			http.Error(w, "Failed to reload profile", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newProfileResponse(user))
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+// positionGap is the spacing between ranks, leaving room to insert cards
+// between neighbours without renumbering the rest of the set.
+const positionGap int64 = 1024
+
+var errCardNotFound = errors.New("flashcard not found in set")
+
+// orderFlashcards sorts cards by their section, then by their rank in the set.
+// Cards outside any section come first.
+func orderFlashcards(query *gorm.DB) *gorm.DB {
+	return query.Joins("LEFT JOIN sections ON sections.id = flashcards.section_id").
+		Order("sections.position ASC NULLS FIRST").
+		Order("flashcards.position ASC").
+		Order("flashcards.id ASC")
+}
+
+// nextCardPosition returns a rank after every card already in the set.
+func nextCardPosition(db *gorm.DB, setID uint) int64 {
+	var last models.Flashcard
+	if err := db.Where("set_id = ?", setID).Order("position desc").First(&last).Error; err != nil {
+		return positionGap
+	}
+	return last.Position + positionGap
+}
+
+func nextSectionPosition(db *gorm.DB, setID uint) int64 {
+	var last models.Section
+	if err := db.Where("set_id = ?", setID).Order("position desc").First(&last).Error; err != nil {
+		return positionGap
+	}
+	return last.Position + positionGap
+}
+
+// moveFlashcards places the cards with the given public IDs, in that order,
+// directly after the card named by after, or at the front when after is empty.
+// Only the moved cards are rewritten unless there's no gap left between their
+// new neighbours, in which case the whole set is renumbered.
+func moveFlashcards(tx *gorm.DB, setID uint, publicIDs []string, after string) error {
+	var cards []models.Flashcard
+	if err := tx.Select("id", "public_id", "position").Where("set_id = ?", setID).Order("position asc, id asc").Find(&cards).Error; err != nil {
+		return err
+	}
+	byPublicID := make(map[string]models.Flashcard, len(cards))
+	for _, fc := range cards {
+		byPublicID[fc.PublicID] = fc
+	}
+	moving := make([]models.Flashcard, 0, len(publicIDs))
+	isMoving := make(map[uint]bool, len(publicIDs))
+	for _, publicID := range publicIDs {
+		fc, ok := byPublicID[publicID]
+		if !ok || isMoving[fc.ID] {
+			return errCardNotFound
+		}
+		moving = append(moving, fc)
+		isMoving[fc.ID] = true
+	}
+
+	remaining := make([]models.Flashcard, 0, len(cards))
+	insertAt := 0
+	foundAfter := after == ""
+	for _, fc := range cards {
+		if isMoving[fc.ID] {
+			if fc.PublicID == after {
+				return errCardNotFound
+			}
+			continue
+		}
+		remaining = append(remaining, fc)
+		if fc.PublicID == after {
+			insertAt = len(remaining)
+			foundAfter = true
+		}
+	}
+	if !foundAfter {
+		return errCardNotFound
+	}
+
+	span := positionGap * int64(len(moving)+1)
+	var prev, next int64
+	switch {
+	case len(remaining) == 0:
+		prev, next = 0, span
+	case insertAt == 0:
+		next = remaining[0].Position
+		prev = next - span
+	case insertAt == len(remaining):
+		prev = remaining[insertAt-1].Position
+		next = prev + span
+	default:
+		prev, next = remaining[insertAt-1].Position, remaining[insertAt].Position
+	}
+
+	step := (next - prev) / int64(len(moving)+1)
+	if step >= 1 {
+		for i, fc := range moving {
+			if err := tx.Model(&models.Flashcard{}).Where("id = ?", fc.ID).Update("position", prev+step*int64(i+1)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ordered := make([]models.Flashcard, 0, len(cards))
+	ordered = append(ordered, remaining[:insertAt]...)
+	ordered = append(ordered, moving...)
+	ordered = append(ordered, remaining[insertAt:]...)
+	for i, fc := range ordered {
+		position := positionGap * int64(i+1)
+		if fc.Position == position {
+			continue
+		}
+		if err := tx.Model(&models.Flashcard{}).Where("id = ?", fc.ID).Update("position", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PUT /api/sets/{setID}/flashcards/order
+func (db *DBHandler) ReorderFlashcards(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Flashcards []string `json:"Flashcards"`        // Public IDs of the cards to move, in their new order
+		After      string   `json:"After"`             // Public ID of the card to place them after, "" for the front
+		Section    *string  `json:"Section,omitempty"` // Public ID of a section to move them into, "" for none
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Flashcards) == 0 {
+		http.Error(w, "Flashcards is required", http.StatusBadRequest)
+		return
+	}
+	var sectionID *uint
+	if req.Section != nil && *req.Section != "" {
+		var section models.Section
+		if err := db.Where("public_id = ? AND set_id = ?", *req.Section, set.ID).First(&section).Error; err != nil {
+			http.Error(w, "Section not found in set", http.StatusNotFound)
+			return
+		}
+		sectionID = &section.ID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := moveFlashcards(tx, set.ID, req.Flashcards, req.After); err != nil {
+			return err
+		}
+		if req.Section == nil {
+			return nil
+		}
+		return tx.Model(&models.Flashcard{}).Where("set_id = ? AND public_id IN ?", set.ID, req.Flashcards).Update("section_id", sectionID).Error
+	})
+	if errors.Is(err, errCardNotFound) {
+		http.Error(w, "Flashcards and After must be distinct cards in the set", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reorder flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	var flashcards []models.Flashcard
+	if err := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID)).Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flashcards)
+}
+
+// GET /api/sets/{setID}/sections
+func (db *DBHandler) GetSectionsForSet(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadReadableSet(w, r)
+	if !ok {
+		return
+	}
+	var sections []models.Section
+	if err := db.Where("set_id = ?", set.ID).Order("position asc, id asc").Find(&sections).Error; err != nil {
+		http.Error(w, "Failed to fetch sections", http.StatusInternalServerError)
+		return
+	}
+	var flashcards []models.Flashcard
+	if err := db.Where("set_id = ?", set.ID).Order("position asc, id asc").Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return
+	}
+
+	type SectionWithCards struct {
+		Section    *models.Section `json:",omitempty"` // nil for cards outside any section
+		Flashcards []models.Flashcard
+	}
+	groups := make([]SectionWithCards, len(sections)+1)
+	index := make(map[uint]int, len(sections))
+	groups[0].Flashcards = []models.Flashcard{}
+	for i := range sections {
+		groups[i+1].Section = &sections[i]
+		groups[i+1].Flashcards = []models.Flashcard{}
+		index[sections[i].ID] = i + 1
+	}
+	for _, fc := range flashcards {
+		i := 0
+		if fc.SectionID != nil {
+			if j, ok := index[*fc.SectionID]; ok {
+				i = j
+			}
+		}
+		groups[i].Flashcards = append(groups[i].Flashcards, fc)
+	}
+	if len(groups[0].Flashcards) == 0 {
+		groups = groups[1:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// POST /api/sets/{setID}/sections
+func (db *DBHandler) CreateSection(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Title    string `json:"Title"`
+		Position *int64 `json:"Position,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || len(req.Title) > 200 {
+		http.Error(w, "Title is required and must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+	position := nextSectionPosition(db.DB, set.ID)
+	if req.Position != nil {
+		position = *req.Position
+	}
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	section := models.Section{
+		SetID:    set.ID,
+		PublicID: publicID,
+		Title:    req.Title,
+		Position: position,
+	}
+	if err := db.Omit("FlashcardSet").Create(&section).Error; err != nil {
+		http.Error(w, "Failed to create section", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(section)
+}
+
+// PUT /api/sets/{setID}/sections/{sectionID}
+func (db *DBHandler) UpdateSection(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var section models.Section
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("sectionID"), set.ID).First(&section).Error; err != nil {
+		http.Error(w, "Section not found in set", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Title    *string `json:"Title,omitempty"`
+		Position *int64  `json:"Position,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Title != nil {
+		section.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Position != nil {
+		section.Position = *req.Position
+	}
+	if section.Title == "" || len(section.Title) > 200 {
+		http.Error(w, "Title is required and must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+	if err := db.Omit("FlashcardSet").Save(&section).Error; err != nil {
+		http.Error(w, "Failed to update section", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(section)
+}
+
+// DELETE /api/sets/{setID}/sections/{sectionID}
+func (db *DBHandler) DeleteSection(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var section models.Section
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("sectionID"), set.ID).First(&section).Error; err != nil {
+		http.Error(w, "Section not found in set", http.StatusNotFound)
+		return
+	}
+	// Cards keep their rank and move out of the section
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Flashcard{}).Where("section_id = ?", section.ID).Update("section_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&section).Error
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete section", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
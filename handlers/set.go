@@ -18,15 +18,15 @@ func (db *DBHandler) GetSetByID(w http.ResponseWriter, r *http.Request) {
 	setID := r.PathValue("setID")
 	var set models.FlashcardSet
 	// Preload the User to access Auth0ID without a separate query
-	if err := db.Preload("User").Preload("Flashcards").Where("public_id = ?", setID).First(&set).Error; err != nil {
+	if err := db.Preload("User").Preload("Flashcards", orderFlashcards).Where("public_id = ?", setID).First(&set).Error; err != nil {
 		log.Printf("GetSetByID: Set not found for public_id=%s: %v", setID, err)
 		http.Error(w, fmt.Sprintf("Set with ID %s not found", setID), http.StatusNotFound)
 		return
 	}
 
 	// Lazy migration for public_id
-	auth0ID, ok := utils.GetAuth0ID(r)
-	isOwner := ok && set.User.Auth0ID == auth0ID
+	auth0ID, _ := utils.GetAuth0ID(r)
+	isOwner := db.hasSetRole(r, &set, models.OrgEditor)
 
 	type SetResponse struct {
 		models.FlashcardSet
@@ -38,7 +38,7 @@ func (db *DBHandler) GetSetByID(w http.ResponseWriter, r *http.Request) {
 		IsOwner:      isOwner,
 	}
 
-	if set.IsPublic {
+	if set.IsPublic || db.sharedWith(r, set.ID, 0, models.ShareScopeView) {
 		log.Printf("GetSetByID: Returning public set %s", setID)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
@@ -46,7 +46,7 @@ func (db *DBHandler) GetSetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !ok || set.User.Auth0ID != auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgViewer) {
 		log.Printf("GetSetByID: Forbidden access for set %s by auth0ID=%s", setID, auth0ID)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
@@ -78,8 +78,11 @@ func (db *DBHandler) CreateFlashCardSet(w http.ResponseWriter, r *http.Request)
 
 	// Decode the request body
 	type CreateSetRequest struct {
-		Title    string `json:"Title"`
-		IsPublic bool   `json:"IsPublic"`
+		Title     string `json:"Title"`
+		IsPublic  *bool  `json:"IsPublic"` // The user's PublicByDefault setting when omitted
+		Direction string `json:"Direction"`
+		// Public ID of the organization to create the set in; empty for a personal set
+		Organization string `json:"Organization"`
 	}
 	var req CreateSetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -87,6 +90,31 @@ func (db *DBHandler) CreateFlashCardSet(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.Direction == "" {
+		req.Direction = models.DirectionForward
+	}
+	isPublic := user.PublicByDefault
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+	if !validDirection(req.Direction) {
+		http.Error(w, "Direction must be forward, reverse or both", http.StatusBadRequest)
+		return
+	}
+
+	var orgID *uint
+	if req.Organization != "" {
+		var org models.Organization
+		if err := db.Where("public_id = ?", req.Organization).First(&org).Error; err != nil {
+			http.Error(w, "Organization not found", http.StatusNotFound)
+			return
+		}
+		if models.OrgRoleRank(orgRole(db.DB, org.ID, user.ID)) < models.OrgRoleRank(models.OrgEditor) {
+			http.Error(w, "Only organization editors can create sets in it", http.StatusForbidden)
+			return
+		}
+		orgID = &org.ID
+	}
 
 	publicID, err := gonanoid.New()
 	if err != nil {
@@ -97,10 +125,13 @@ func (db *DBHandler) CreateFlashCardSet(w http.ResponseWriter, r *http.Request)
 
 	// Create the set
 	set := models.FlashcardSet{
-		Title:    req.Title,
-		UserID:   user.ID,
-		IsPublic: req.IsPublic,
-		PublicID: publicID,
+		Title:     req.Title,
+		UserID:    user.ID,
+		IsPublic:  isPublic,
+		PublicID:  publicID,
+		Direction: req.Direction,
+
+		OrganizationID: orgID,
 	}
 
 	// Save to DB
@@ -133,7 +164,7 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if auth0ID != set.User.Auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgEditor) {
 		log.Printf("UpdateSetByID: Unauthorized update attempt by auth0ID=%s for setID=%s", auth0ID, setID)
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
@@ -145,6 +176,9 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 		Term         string `json:"Term"`
 		Solution     string `json:"Solution"`
 		Concept      string `json:"Concept"`
+		Format       string `json:"Format"`
+		Language     string `json:"Language"`
+		Type         string `json:"Type"`
 		ShouldDelete bool   `json:"shouldDelete"`
 		ShouldUpdate bool   `json:"shouldUpdate"`
 		ShouldCreate bool   `json:"shouldCreate"`
@@ -152,6 +186,7 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 	type UpdateSetRequest struct {
 		Title      *string            `json:"title,omitempty"`
 		IsPublic   *bool              `json:"isPublic,omitempty"`
+		Direction  *string            `json:"direction,omitempty"`
 		Flashcards *[]FlashcardUpdate `json:"Flashcards,omitempty"`
 	}
 
@@ -177,15 +212,28 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 		set.IsPublic = *req.IsPublic
 		updated = true
 	}
+	if req.Direction != nil && set.Direction != *req.Direction {
+		if !validDirection(*req.Direction) {
+			http.Error(w, "direction must be forward, reverse or both", http.StatusBadRequest)
+			return
+		}
+		set.Direction = *req.Direction
+		updated = true
+	}
 
 	// Support shouldDelete, shouldUpdate, shouldCreate flags for flashcards
 	if req.Flashcards != nil {
 		for _, fc := range *req.Flashcards {
 			if fc.ID != 0 {
 				if fc.ShouldDelete {
-					// Delete flashcard
+					// Delete flashcard and its attachments
 					if err := db.Where("id = ? AND set_id = ?", fc.ID, set.ID).Delete(&models.Flashcard{}).Error; err != nil {
 						log.Printf("UpdateSetByID: Failed to delete flashcard id=%d for setID=%s: %v", fc.ID, setID, err)
+						continue
+					}
+					cardIDs := db.Unscoped().Model(&models.Flashcard{}).Select("id").Where("id = ? AND set_id = ?", fc.ID, set.ID)
+					if err := db.deleteCardMedia(r.Context(), cardIDs); err != nil {
+						log.Printf("UpdateSetByID: Failed to delete media for flashcard id=%d for setID=%s: %v", fc.ID, setID, err)
 					}
 					continue
 				}
@@ -199,6 +247,18 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 					flashcard.Term = fc.Term
 					flashcard.Solution = fc.Solution
 					flashcard.Concept = fc.Concept
+					// Older clients don't send a format, so keep the card's own
+					if fc.Format != "" {
+						flashcard.Format = fc.Format
+						flashcard.Language = fc.Language
+					}
+					if fc.Type != "" {
+						flashcard.Type = fc.Type
+					}
+					if err := validateFlashcardContent(&flashcard); err != nil {
+						log.Printf("UpdateSetByID: Invalid content for flashcard id=%d for setID=%s: %v", fc.ID, setID, err)
+						continue
+					}
 					if err := db.Save(&flashcard).Error; err != nil {
 						log.Printf("UpdateSetByID: Failed to update flashcard id=%d for setID=%s: %v", fc.ID, setID, err)
 					}
@@ -223,6 +283,15 @@ func (db *DBHandler) UpdateSetByID(w http.ResponseWriter, r *http.Request) {
 						SetID:    set.ID,
 						PublicID: publicID,
 					}
+					newFlashcard.Format = fc.Format
+					newFlashcard.Language = fc.Language
+					newFlashcard.Type = fc.Type
+					if err := validateFlashcardContent(&newFlashcard); err != nil {
+						log.Printf("UpdateSetByID: Invalid content for new flashcard for setID=%s: %v", setID, err)
+						continue
+					}
+					newFlashcard.ConceptID = conceptForText(db.DB, set.ID, fc.Concept)
+					newFlashcard.Position = nextCardPosition(db.DB, set.ID)
 					if err := db.Create(&newFlashcard).Error; err != nil {
 						log.Printf("UpdateSetByID: Failed to create new flashcard for setID=%s: %v", setID, err)
 					}
@@ -263,7 +332,7 @@ func (db *DBHandler) DeleteSetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if auth0ID != set.User.Auth0ID {
+	if !db.hasSetRole(r, &set, models.OrgAdmin) {
 		log.Printf("DeleteSetByID: Unauthorized delete attempt by auth0ID=%s for setID=%s", auth0ID, setID)
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
@@ -283,6 +352,12 @@ func (db *DBHandler) DeleteSetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The set is soft-deleted, so its cards' attachments are removed here
+	cardIDs := db.Unscoped().Model(&models.Flashcard{}).Select("id").Where("set_id = ?", set.ID)
+	if err := db.deleteCardMedia(r.Context(), cardIDs); err != nil {
+		log.Printf("DeleteSetByID: Failed to delete media for setID=%s: %v", setID, err)
+	}
+
 	log.Printf("DeleteSetByID: Successfully deleted setID=%s", setID)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -305,7 +380,7 @@ func (db *DBHandler) GetSetsForUser(w http.ResponseWriter, r *http.Request) {
 	auth0ID, ok := utils.GetAuth0ID(r)
 
 	var sets []models.FlashcardSet
-	query := db.Preload("Flashcards").Where("user_id = ?", user.ID)
+	query := db.Preload("Flashcards", orderFlashcards).Where("user_id = ?", user.ID).Scopes(tenantScope(nil))
 
 	if ok && user.Auth0ID == auth0ID {
 		//log.Printf("GetSetsForUser: Returning all sets for owner userID=%d", user.ID)
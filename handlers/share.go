@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+// shareTokenHeader carries a share link token on API requests; ?share= works too
+// for links opened straight from a URL.
+const shareTokenHeader = "X-Share-Token"
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func shareToken(r *http.Request) string {
+	if token := r.Header.Get(shareTokenHeader); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("share")
+}
+
+// sharedWith reports whether a private set, or one of its mind maps when
+// mindMapID isn't 0, has been shared with the caller for scope, either by a
+// share link or, for the set itself, by being assigned to one of their
+// classrooms.
+func (db *DBHandler) sharedWith(r *http.Request, setID, mindMapID uint, scope string) bool {
+	if db.sharedByLink(r, setID, mindMapID, scope) {
+		return true
+	}
+	// Assigning a set hands out its cards, not the owner's private mind maps
+	return mindMapID == 0 && db.assignedToCaller(r, setID)
+}
+
+// sharedByLink reports whether the request carries a live share link granting
+// scope. A set link covers all of the set's mind maps; a mind map link covers
+// only that map. Study links also grant view access.
+func (db *DBHandler) sharedByLink(r *http.Request, setID, mindMapID uint, scope string) bool {
+	token := shareToken(r)
+	if token == "" {
+		return false
+	}
+	var link models.ShareLink
+	if err := db.Where("token_hash = ? AND set_id = ?", hashShareToken(token), setID).First(&link).Error; err != nil {
+		return false
+	}
+	if !link.Live(time.Now()) {
+		return false
+	}
+	if link.MindMapID != nil && *link.MindMapID != mindMapID {
+		return false
+	}
+	return scope == models.ShareScopeView || link.Scope == scope
+}
+
+type shareLinkResponse struct {
+	models.ShareLink
+	MindMap string `json:",omitempty"` // Public ID of the shared mind map
+	Token   string `json:",omitempty"` // Only returned when the link is created
+	Live    bool   `json:"Live"`
+}
+
+// GET /api/sets/{setID}/share-links
+func (db *DBHandler) GetShareLinks(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var links []models.ShareLink
+	if err := db.Preload("MindMap").Where("set_id = ?", set.ID).Order("created_at desc").Find(&links).Error; err != nil {
+		http.Error(w, "Failed to fetch share links", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	response := make([]shareLinkResponse, 0, len(links))
+	for _, link := range links {
+		item := shareLinkResponse{ShareLink: link, Live: link.Live(now)}
+		if link.MindMap != nil {
+			item.MindMap = link.MindMap.PublicID
+		}
+		response = append(response, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// POST /api/sets/{setID}/share-links
+func (db *DBHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Scope     string     `json:"Scope"`
+		MindMap   string     `json:"MindMap"` // Public ID, "" to share the whole set
+		ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.ShareScopeView
+	}
+	if req.Scope != models.ShareScopeView && req.Scope != models.ShareScopeStudy {
+		http.Error(w, "Scope must be view or study", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "ExpiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	response := shareLinkResponse{Live: true}
+	var mindMapID *uint
+	if req.MindMap != "" {
+		var mindMap models.MindMap
+		if err := db.Where("public_id = ? AND set_id = ?", req.MindMap, set.ID).First(&mindMap).Error; err != nil {
+			http.Error(w, "MindMap not found in set", http.StatusNotFound)
+			return
+		}
+		mindMapID = &mindMap.ID
+		response.MindMap = mindMap.PublicID
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	publicID, err := gonanoid.New()
+	if err != nil {
+		http.Error(w, "Failed to generate public_id", http.StatusInternalServerError)
+		return
+	}
+	link := models.ShareLink{
+		PublicID:  publicID,
+		TokenHash: hashShareToken(token),
+		SetID:     set.ID,
+		MindMapID: mindMapID,
+		Scope:     req.Scope,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := db.Omit("FlashcardSet", "MindMap").Create(&link).Error; err != nil {
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	response.ShareLink = link
+	response.Token = token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DELETE /api/sets/{setID}/share-links/{linkID}
+func (db *DBHandler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadOwnedSet(w, r)
+	if !ok {
+		return
+	}
+	var link models.ShareLink
+	if err := db.Where("public_id = ? AND set_id = ?", r.PathValue("linkID"), set.ID).First(&link).Error; err != nil {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	if link.RevokedAt == nil {
+		if err := db.Model(&link).Update("revoked_at", time.Now()).Error; err != nil {
+			http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/share/{token}
+//
+// Opens a share link, counting the use, and says what it grants access to.
+// Later requests for the resource pass the same token in the X-Share-Token
+// header.
+func (db *DBHandler) OpenShareLink(w http.ResponseWriter, r *http.Request) {
+	var link models.ShareLink
+	if err := db.Preload("FlashcardSet").Preload("MindMap").
+		Where("token_hash = ?", hashShareToken(r.PathValue("token"))).First(&link).Error; err != nil {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	if !link.Live(now) {
+		http.Error(w, "Share link has expired or been revoked", http.StatusGone)
+		return
+	}
+
+	if err := db.Model(&models.ShareLink{}).Where("id = ?", link.ID).
+		UpdateColumns(map[string]any{"use_count": gorm.Expr("use_count + 1"), "last_used_at": now}).Error; err != nil {
+		http.Error(w, "Failed to open share link", http.StatusInternalServerError)
+		return
+	}
+
+	type SharedResource struct {
+		Scope     string
+		Set       string // Public ID
+		SetTitle  string
+		MindMap   string `json:",omitempty"` // Public ID, when only a mind map is shared
+		ExpiresAt *time.Time
+	}
+	shared := SharedResource{
+		Scope:     link.Scope,
+		Set:       link.FlashcardSet.PublicID,
+		SetTitle:  link.FlashcardSet.Title,
+		ExpiresAt: link.ExpiresAt,
+	}
+	if link.MindMap != nil {
+		shared.MindMap = link.MindMap.PublicID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shared)
+}
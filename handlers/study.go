@@ -0,0 +1,666 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+)
+
+const (
+	defaultStudyLimit  = 20
+	maxStudyLimit      = 100
+	minEaseFactor      = 1.3
+	matureIntervalDays = 21
+	relearnDelay       = 10 * time.Minute
+
+	defaultSetQuizQuestions = 10
+	maxSetQuizQuestions     = 50
+	setQuizOptions          = 4
+)
+
+// leitnerIntervals are the days a card waits in each Leitner box; a card
+// moves up a box every time it's remembered
+var leitnerIntervals = []int{1, 3, 7, 14, 30, 60, 120}
+
+func validDirection(direction string) bool {
+	switch direction {
+	case models.DirectionForward, models.DirectionReverse, models.DirectionBoth:
+		return true
+	}
+	return false
+}
+
+// studyDirections lists the directions a set's cards are studied in.
+func studyDirections(set *models.FlashcardSet) []string {
+	switch set.Direction {
+	case models.DirectionReverse:
+		return []string{models.DirectionReverse}
+	case models.DirectionBoth:
+		return []string{models.DirectionForward, models.DirectionReverse}
+	default:
+		return []string{models.DirectionForward}
+	}
+}
+
+// studyUnit is one independently scheduled part of a card: a direction of a
+// basic card, or one cloze number of a cloze card.
+type studyUnit struct {
+	Direction string
+	Item      string
+}
+
+// studyUnits lists the units a card is studied as. Cloze cards ignore the
+// set's direction and are always studied forward, once per cloze number.
+func studyUnits(set *models.FlashcardSet, fc models.Flashcard) []studyUnit {
+	if fc.Type == models.CardCloze {
+		deletions, _ := utils.ParseCloze(fc.Solution)
+		var units []studyUnit
+		for _, index := range utils.ClozeIndexes(deletions) {
+			units = append(units, studyUnit{Direction: models.DirectionForward, Item: utils.ClozeItem(index)})
+		}
+		return units
+	}
+	var units []studyUnit
+	for _, direction := range studyDirections(set) {
+		units = append(units, studyUnit{Direction: direction})
+	}
+	return units
+}
+
+// cardSides returns the prompt and answer for one unit of a card.
+func cardSides(fc models.Flashcard, unit studyUnit) (string, string) {
+	if index, ok := utils.ParseClozeItem(unit.Item); ok && fc.Type == models.CardCloze {
+		return utils.ClozeQuestion(fc.Solution, index), utils.ClozeAnswer(fc.Solution)
+	}
+	if unit.Direction == models.DirectionReverse {
+		return fc.Solution, fc.Term
+	}
+	return fc.Term, fc.Solution
+}
+
+// quizSides is cardSides with a cloze answer cut down to the hidden text.
+func quizSides(fc models.Flashcard, unit studyUnit) (string, string) {
+	prompt, answer := cardSides(fc, unit)
+	if index, ok := utils.ParseClozeItem(unit.Item); ok && fc.Type == models.CardCloze {
+		answer = utils.ClozeAnswerText(fc.Solution, index)
+	}
+	return prompt, answer
+}
+
+func quizPool(unit studyUnit) string {
+	if unit.Item != "" {
+		return "cloze"
+	}
+	return unit.Direction
+}
+
+// scheduleReview applies a review graded 0 (blackout) to 5 (perfect) with the
+// user's scheduler, SM-2 unless they chose Leitner boxes. Either way a failed
+// card comes back after relearnDelay and starts its intervals over.
+func scheduleReview(state *models.ReviewState, grade int, now time.Time, scheduler string) {
+	if state.EaseFactor == 0 {
+		state.EaseFactor = 2.5
+	}
+	if grade < 3 {
+		if state.Repetitions > 0 {
+			state.Lapses++
+		}
+		state.Repetitions = 0
+		state.IntervalDays = 0
+		state.DueAt = now.Add(relearnDelay)
+	} else if scheduler == models.SchedulerLeitner {
+		state.IntervalDays = leitnerIntervals[min(state.Repetitions, len(leitnerIntervals)-1)]
+		state.Repetitions++
+		state.DueAt = now.AddDate(0, 0, state.IntervalDays)
+	} else {
+		switch state.Repetitions {
+		case 0:
+			state.IntervalDays = 1
+		case 1:
+			state.IntervalDays = 6
+		default:
+			state.IntervalDays = int(math.Round(float64(state.IntervalDays) * state.EaseFactor))
+		}
+		state.Repetitions++
+		state.DueAt = now.AddDate(0, 0, state.IntervalDays)
+	}
+	// Leitner boxes don't use the ease factor, but keeping it current lets
+	// the user switch back to SM-2
+	q := float64(5 - grade)
+	state.EaseFactor = max(minEaseFactor, state.EaseFactor+0.1-q*(0.08+q*0.02))
+	state.LastReviewed = &now
+}
+
+type studyItem struct {
+	Flashcard string // Public ID
+	Direction string
+	Item      string `json:",omitempty"` // Cloze number, for cloze cards
+	Prompt    string
+	Answer    string
+	Format    string
+	Language  string     `json:",omitempty"`
+	New       bool       // Never reviewed in this direction
+	DueAt     *time.Time `json:",omitempty"`
+}
+
+func newStudyItem(fc models.Flashcard, unit studyUnit, state *models.ReviewState) studyItem {
+	prompt, answer := cardSides(fc, unit)
+	item := studyItem{
+		Flashcard: fc.PublicID,
+		Direction: unit.Direction,
+		Item:      unit.Item,
+		Prompt:    prompt,
+		Answer:    answer,
+		Format:    fc.Format,
+		Language:  fc.Language,
+		New:       state == nil,
+	}
+	if state != nil {
+		item.DueAt = &state.DueAt
+	}
+	return item
+}
+
+// currentUser looks up the caller's user row.
+func (db *DBHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	auth0ID, ok := utils.GetAuth0ID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	var user models.User
+	if err := db.Where("auth0_id = ?", auth0ID).First(&user).Error; err != nil {
+		http.Error(w, "User not found in database", http.StatusNotFound)
+		return nil, false
+	}
+	return &user, true
+}
+
+// studyCards loads a set's cards in study order, limited to ?concept= when given.
+func (db *DBHandler) studyCards(w http.ResponseWriter, r *http.Request, set *models.FlashcardSet) ([]models.Flashcard, bool) {
+	concept, err := conceptFilter(db.DB, r, set.ID)
+	if err != nil {
+		http.Error(w, "Concept not found in set", http.StatusNotFound)
+		return nil, false
+	}
+	query := orderFlashcards(db.Where("flashcards.set_id = ?", set.ID))
+	if concept != nil {
+		query = query.Where("flashcards.concept_id = ?", concept.ID)
+	}
+	var flashcards []models.Flashcard
+	if err := query.Find(&flashcards).Error; err != nil {
+		http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+		return nil, false
+	}
+	return flashcards, true
+}
+
+// reviewStates indexes a user's review states for the given cards by card and unit.
+func reviewStates(db *gorm.DB, userID uint, flashcards []models.Flashcard) (map[uint]map[studyUnit]*models.ReviewState, error) {
+	ids := make([]uint, 0, len(flashcards))
+	for _, fc := range flashcards {
+		ids = append(ids, fc.ID)
+	}
+	var states []models.ReviewState
+	if len(ids) > 0 {
+		if err := db.Where("user_id = ? AND flashcard_id IN ?", userID, ids).Find(&states).Error; err != nil {
+			return nil, err
+		}
+	}
+	byCard := make(map[uint]map[studyUnit]*models.ReviewState, len(states))
+	for i := range states {
+		if byCard[states[i].FlashcardID] == nil {
+			byCard[states[i].FlashcardID] = make(map[studyUnit]*models.ReviewState)
+		}
+		byCard[states[i].FlashcardID][studyUnit{states[i].Direction, states[i].Item}] = &states[i]
+	}
+	return byCard, nil
+}
+
+// studyDayStart returns midnight at the start of the user's current day in
+// their time zone, which is when daily limits and goals reset.
+func studyDayStart(user *models.User, now time.Time) time.Time {
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	year, month, day := now.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+type dailyProgress struct {
+	NewCards     int // Cards studied for the first time today, across all sets
+	NewCardLimit int
+	Reviews      int // Cards reviewed today, new ones included
+	ReviewGoal   int
+}
+
+func todaysProgress(db *gorm.DB, user *models.User, now time.Time) (dailyProgress, error) {
+	since := studyDayStart(user, now)
+	var newCards, reviews int64
+	if err := db.Model(&models.ReviewState{}).Where("user_id = ? AND created_at >= ?", user.ID, since).Count(&newCards).Error; err != nil {
+		return dailyProgress{}, err
+	}
+	if err := db.Model(&models.ReviewState{}).Where("user_id = ? AND last_reviewed >= ?", user.ID, since).Count(&reviews).Error; err != nil {
+		return dailyProgress{}, err
+	}
+	return dailyProgress{
+		NewCards:     int(newCards),
+		NewCardLimit: user.DailyNewCards,
+		Reviews:      int(reviews),
+		ReviewGoal:   user.DailyReviewGoal,
+	}, nil
+}
+
+// GET /api/sets/{setID}/study?limit=&concept=
+func (db *DBHandler) GetStudyQueue(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadSharedSet(w, r, models.ShareScopeStudy)
+	if !ok {
+		return
+	}
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultStudyLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxStudyLimit)
+	}
+	flashcards, ok := db.studyCards(w, r, set)
+	if !ok {
+		return
+	}
+	states, err := reviewStates(db.DB, user.ID, flashcards)
+	if err != nil {
+		http.Error(w, "Failed to fetch review history", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	today, err := todaysProgress(db.DB, user, now)
+	if err != nil {
+		http.Error(w, "Failed to fetch review history", http.StatusInternalServerError)
+		return
+	}
+
+	// Due reviews come first, oldest due first, then new cards in set order
+	// while the user's daily new card limit allows
+	var due, fresh []studyItem
+	for _, fc := range flashcards {
+		for _, unit := range studyUnits(set, fc) {
+			state := states[fc.ID][unit]
+			switch {
+			case state == nil:
+				fresh = append(fresh, newStudyItem(fc, unit, nil))
+			case !state.DueAt.After(now):
+				due = append(due, newStudyItem(fc, unit, state))
+			}
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].DueAt.Before(*due[j].DueAt) })
+
+	queue := due[:min(len(due), limit)]
+	newAllowed := max(0, today.NewCardLimit-today.NewCards)
+	queue = append(queue, fresh[:min(len(fresh), newAllowed, limit-len(queue))]...)
+
+	type StudyQueue struct {
+		SetID     string
+		Direction string
+		DueCount  int
+		NewCount  int
+		Today     dailyProgress
+		Items     []studyItem
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StudyQueue{
+		SetID:     set.PublicID,
+		Direction: set.Direction,
+		DueCount:  len(due),
+		NewCount:  len(fresh),
+		Today:     today,
+		Items:     append([]studyItem{}, queue...),
+	})
+}
+
+// POST /api/sets/{setID}/study/reviews
+func (db *DBHandler) RecordReview(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadSharedSet(w, r, models.ShareScopeStudy)
+	if !ok {
+		return
+	}
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Flashcard string `json:"Flashcard"` // Public ID
+		Direction string `json:"Direction"`
+		Item      string `json:"Item"`  // Cloze number, for cloze cards
+		Grade     int    `json:"Grade"` // 0-5
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Grade < 0 || req.Grade > 5 {
+		http.Error(w, "Grade must be between 0 and 5", http.StatusBadRequest)
+		return
+	}
+	var flashcard models.Flashcard
+	if err := db.Where("public_id = ? AND set_id = ?", req.Flashcard, set.ID).First(&flashcard).Error; err != nil {
+		http.Error(w, "Flashcard not found", http.StatusNotFound)
+		return
+	}
+	units := studyUnits(set, flashcard)
+	if req.Direction == "" && len(units) > 0 {
+		req.Direction = units[0].Direction
+	}
+	unit := studyUnit{Direction: req.Direction, Item: req.Item}
+	allowed := false
+	for _, u := range units {
+		allowed = allowed || u == unit
+	}
+	if !allowed {
+		http.Error(w, "This card isn't studied in that direction or has no such cloze item", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	var state models.ReviewState
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND flashcard_id = ? AND direction = ? AND item = ?", user.ID, flashcard.ID, unit.Direction, unit.Item).
+			Attrs(models.ReviewState{UserID: user.ID, FlashcardID: flashcard.ID, Direction: unit.Direction, Item: unit.Item}).
+			FirstOrInit(&state).Error; err != nil {
+			return err
+		}
+		scheduleReview(&state, req.Grade, now, user.Scheduler)
+		if err := tx.Omit("User", "Flashcard").Save(&state).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&flashcard).UpdateColumns(map[string]any{
+			"times_reviewed": gorm.Expr("times_reviewed + 1"),
+			"last_reviewed":  now,
+		}).Error; err != nil {
+			return err
+		}
+		if set.UserID == user.ID {
+			return tx.Model(set).UpdateColumn("last_studied", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to record review", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// GET /api/sets/{setID}/study/progress?concept=
+func (db *DBHandler) GetStudyProgress(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadSharedSet(w, r, models.ShareScopeStudy)
+	if !ok {
+		return
+	}
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	flashcards, ok := db.studyCards(w, r, set)
+	if !ok {
+		return
+	}
+	states, err := reviewStates(db.DB, user.ID, flashcards)
+	if err != nil {
+		http.Error(w, "Failed to fetch review history", http.StatusInternalServerError)
+		return
+	}
+
+	type DirectionProgress struct {
+		Direction string
+		Total     int
+		New       int // Never reviewed
+		Learning  int // Reviewed but not yet on a multi-day interval
+		Due       int // Scheduled for now or earlier
+		Mature    int // Interval of at least matureIntervalDays
+	}
+	now := time.Now()
+	progress := []DirectionProgress{}
+	index := make(map[string]int)
+	for _, direction := range studyDirections(set) {
+		index[direction] = len(progress)
+		progress = append(progress, DirectionProgress{Direction: direction})
+	}
+	// Cloze items count as forward even in reverse-only sets
+	for _, fc := range flashcards {
+		for _, unit := range studyUnits(set, fc) {
+			i, ok := index[unit.Direction]
+			if !ok {
+				i = len(progress)
+				index[unit.Direction] = i
+				progress = append(progress, DirectionProgress{Direction: unit.Direction})
+			}
+			p := &progress[i]
+			p.Total++
+			state := states[fc.ID][unit]
+			if state == nil {
+				p.New++
+				continue
+			}
+			if state.IntervalDays < 1 {
+				p.Learning++
+			}
+			if !state.DueAt.After(now) {
+				p.Due++
+			}
+			if state.IntervalDays >= matureIntervalDays {
+				p.Mature++
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// GET /api/sets/{setID}/quiz?count=&concept=
+//
+// Builds multiple choice questions from the set's cards in the set's study
+// direction, or from one cloze number of cloze cards, using other cards'
+// answers as distractors.
+func (db *DBHandler) GetSetQuiz(w http.ResponseWriter, r *http.Request) {
+	set, ok := db.loadSharedSet(w, r, models.ShareScopeStudy)
+	if !ok {
+		return
+	}
+	count := defaultSetQuizQuestions
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		count = min(parsed, maxSetQuizQuestions)
+	}
+	flashcards, ok := db.studyCards(w, r, set)
+	if !ok {
+		return
+	}
+
+	type QuizQuestion struct {
+		Flashcard string
+		Direction string
+		Item      string `json:",omitempty"`
+		Prompt    string
+		Options   []string
+		Answer    string
+	}
+	// Distractors come from answers of the same kind: the other side in the
+	// same direction, or other cloze deletions
+	pools := make(map[string][]string)
+	for _, fc := range flashcards {
+		for _, unit := range studyUnits(set, fc) {
+			_, answer := quizSides(fc, unit)
+			pools[quizPool(unit)] = append(pools[quizPool(unit)], answer)
+		}
+	}
+	questions := []QuizQuestion{}
+	for _, i := range rand.Perm(len(flashcards)) {
+		if len(questions) == count {
+			break
+		}
+		units := studyUnits(set, flashcards[i])
+		if len(units) == 0 {
+			continue
+		}
+		unit := units[rand.IntN(len(units))]
+		prompt, answer := quizSides(flashcards[i], unit)
+		pool := pools[quizPool(unit)]
+		options := []string{answer}
+		for _, j := range rand.Perm(len(pool)) {
+			if len(options) == setQuizOptions {
+				break
+			}
+			duplicate := false
+			for _, option := range options {
+				duplicate = duplicate || option == pool[j]
+			}
+			if !duplicate {
+				options = append(options, pool[j])
+			}
+		}
+		rand.Shuffle(len(options), func(a, b int) { options[a], options[b] = options[b], options[a] })
+		questions = append(questions, QuizQuestion{
+			Flashcard: flashcards[i].PublicID,
+			Direction: unit.Direction,
+			Item:      unit.Item,
+			Prompt:    prompt,
+			Options:   options,
+			Answer:    answer,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
+
+// GET /api/study
+//
+// The caller's study queue across sets: classroom assignments first, soonest
+// due first, then any other set they've started with reviews waiting.
+func (db *DBHandler) GetStudyOverview(w http.ResponseWriter, r *http.Request) {
+	user, ok := db.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var assignments []models.ClassroomAssignment
+	if err := db.Preload("FlashcardSet").Preload("Classroom").
+		Joins("JOIN classroom_members ON classroom_members.classroom_id = classroom_assignments.classroom_id").
+		Where("classroom_members.user_id = ? AND classroom_members.role = ?", user.ID, models.ClassroomStudent).
+		Order("classroom_assignments.due_at ASC NULLS LAST").Order("classroom_assignments.id ASC").
+		Find(&assignments).Error; err != nil {
+		http.Error(w, "Failed to fetch assignments", http.StatusInternalServerError)
+		return
+	}
+	var startedSetIDs []uint
+	if err := db.Model(&models.ReviewState{}).
+		Joins("JOIN flashcards ON flashcards.id = review_states.flashcard_id AND flashcards.deleted_at IS NULL").
+		Where("review_states.user_id = ?", user.ID).
+		Distinct().Pluck("flashcards.set_id", &startedSetIDs).Error; err != nil {
+		http.Error(w, "Failed to fetch review history", http.StatusInternalServerError)
+		return
+	}
+
+	type Assignment struct {
+		Assignment string // Public ID
+		Classroom  string // Public ID
+		ClassName  string
+		DueAt      *time.Time
+	}
+	type QueueEntry struct {
+		Set        string // Public ID
+		SetTitle   string
+		DueCount   int
+		NewCount   int
+		Assignment *Assignment `json:",omitempty"`
+	}
+	var entries []QueueEntry
+	var sets []*models.FlashcardSet
+	seen := make(map[uint]bool)
+	for i := range assignments {
+		a := &assignments[i]
+		if seen[a.SetID] {
+			continue
+		}
+		seen[a.SetID] = true
+		sets = append(sets, &a.FlashcardSet)
+		entries = append(entries, QueueEntry{
+			Set:      a.FlashcardSet.PublicID,
+			SetTitle: a.FlashcardSet.Title,
+			Assignment: &Assignment{
+				Assignment: a.PublicID,
+				Classroom:  a.Classroom.PublicID,
+				ClassName:  a.Classroom.Name,
+				DueAt:      a.DueAt,
+			},
+		})
+	}
+	var started []models.FlashcardSet
+	if len(startedSetIDs) > 0 {
+		if err := db.Where("id IN ?", startedSetIDs).Order("last_studied DESC NULLS LAST").Find(&started).Error; err != nil {
+			http.Error(w, "Failed to fetch sets", http.StatusInternalServerError)
+			return
+		}
+	}
+	for i := range started {
+		if seen[started[i].ID] {
+			continue
+		}
+		seen[started[i].ID] = true
+		sets = append(sets, &started[i])
+		entries = append(entries, QueueEntry{Set: started[i].PublicID, SetTitle: started[i].Title})
+	}
+
+	now := time.Now()
+	queue := []QueueEntry{}
+	for i, set := range sets {
+		var flashcards []models.Flashcard
+		if err := db.Where("set_id = ?", set.ID).Find(&flashcards).Error; err != nil {
+			http.Error(w, "Failed to fetch flashcards", http.StatusInternalServerError)
+			return
+		}
+		states, err := reviewStates(db.DB, user.ID, flashcards)
+		if err != nil {
+			http.Error(w, "Failed to fetch review history", http.StatusInternalServerError)
+			return
+		}
+		for _, fc := range flashcards {
+			for _, unit := range studyUnits(set, fc) {
+				state := states[fc.ID][unit]
+				switch {
+				case state == nil:
+					entries[i].NewCount++
+				case !state.DueAt.After(now):
+					entries[i].DueCount++
+				}
+			}
+		}
+		// Sets picked up outside a classroom only show up when something's due
+		if entries[i].Assignment != nil || entries[i].DueCount > 0 {
+			queue = append(queue, entries[i])
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
@@ -4,10 +4,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/andrewpaige1/nodebook-api/config"
 	"github.com/andrewpaige1/nodebook-api/handlers"
 	"github.com/andrewpaige1/nodebook-api/middleware"
+	"github.com/andrewpaige1/nodebook-api/models"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
@@ -25,46 +27,186 @@ func init() {
 func main() {
 	// Initialize database connection
 	config.Connect()
-	authMiddleware := middleware.EnsureValidToken()
 
-	DBHandler := &handlers.DBHandler{DB: config.Database}
+	store, err := config.NewStorage()
+	if err != nil {
+		log.Fatalf("Failed to set up media storage: %v", err)
+	}
+
+	DBHandler := &handlers.DBHandler{
+		DB:             config.Database,
+		Storage:        store,
+		MediaURLSecret: config.MediaURLSecret(),
+	}
+	// Accounts past their deletion grace period and expired exports are
+	// cleaned up in the background
+	go DBHandler.RunAccountCleanup(time.Hour)
+
 	mux := http.NewServeMux()
 
-	// Set
-	mux.HandleFunc("GET /api/sets/{setID}", DBHandler.GetSetByID)
-	mux.HandleFunc("POST /api/sets", middleware.SyncUserMiddleware(DBHandler.CreateFlashCardSet))
-	mux.HandleFunc("PUT /api/sets/{setID}", middleware.SyncUserMiddleware(DBHandler.UpdateSetByID))
-	mux.HandleFunc("DELETE /api/sets/{setID}", middleware.SyncUserMiddleware(DBHandler.DeleteSetByID))
-
-	// User sets
-	mux.HandleFunc("GET /api/users/{nickname}/sets", DBHandler.GetSetsForUser)
-	mux.HandleFunc("GET /api/users/{nickname}/mindmaps", DBHandler.GetMindMapsForUser)
-
-	// Mind map
-	mux.HandleFunc("GET /api/sets/{setID}/mindmaps/{mindMapID}", DBHandler.GetMindMapByID)
-	mux.HandleFunc("GET /api/sets/{setID}/mindmaps", DBHandler.GetMindMapsForSet)
-	mux.HandleFunc("POST /api/sets/{setID}/mindmaps", middleware.SyncUserMiddleware(DBHandler.CreateMindMap))
-	mux.HandleFunc("PUT /api/sets/{setID}/mindmaps/{mindMapID}", middleware.SyncUserMiddleware(DBHandler.UpdateMindMapByID))
-	mux.HandleFunc("DELETE /api/sets/{setID}/mindmaps/{mindMapID}", middleware.SyncUserMiddleware(DBHandler.DeleteMindMapByID))
-	mux.HandleFunc("PUT /api/sets/{setID}/mindmaps/{mindMapID}/connections", DBHandler.UpdateMindMapConnections)
-	mux.HandleFunc("PUT /api/sets/{setID}/mindmaps/{mindMapID}/layouts", DBHandler.UpdateMindMapLayouts)
-
-	// Blocks
-	mux.HandleFunc("GET /api/blocks/leaderboard/{setID}", DBHandler.GetBlocksLeaderboard)
-	mux.HandleFunc("POST /api/blocks/score/{setID}", DBHandler.CreateBlockScore)
-
-	// Flashcard
-	mux.HandleFunc("POST /api/sets/{setID}/flashcards/", middleware.SyncUserMiddleware(DBHandler.CreateFlashCard))
-	mux.HandleFunc("GET /api/sets/{setID}/flashcards/{flashcardID}", middleware.SyncUserMiddleware(DBHandler.GetFlashcardByID))
-	mux.HandleFunc("GET /api/sets/{setID}/flashcards", DBHandler.GetFlashcardsForSet)
-	mux.HandleFunc("PUT /api/sets/{setID}/flashcards/{flashcardID}", middleware.SyncUserMiddleware(DBHandler.UpdateFlashCardByID))
-	mux.HandleFunc("DELETE /api/sets/{setID}/flashcards/{flashcardID}", middleware.SyncUserMiddleware(DBHandler.DeleteFlashCardByID))
+	// Every endpoint, with who may call it and the scope their token needs.
+	// Routes are AuthRequired unless marked otherwise; public sets and mind
+	// maps are readable without an account and the handlers themselves decide
+	// what an anonymous caller may see. Tokens are held to each route's
+	// scope unless they're JWTs issued to a client in AUTH_SCOPE_EXEMPT_CLIENTS.
+	routes := []middleware.Route{
+		// Set
+		{Pattern: "GET /api/sets/{setID}", Handler: DBHandler.GetSetByID, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets", Handler: middleware.SyncUserMiddleware(DBHandler.CreateFlashCardSet), Scope: models.ScopeWriteSets},
+		{Pattern: "PUT /api/sets/{setID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateSetByID), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteSetByID), Scope: models.ScopeWriteSets},
+
+		// User sets
+		{Pattern: "GET /api/users/{nickname}/sets", Handler: DBHandler.GetSetsForUser, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "GET /api/users/{nickname}/mindmaps", Handler: DBHandler.GetMindMapsForUser, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+
+		// Mind map
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}", Handler: DBHandler.GetMindMapByID, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps", Handler: DBHandler.GetMindMapsForSet, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps", Handler: middleware.SyncUserMiddleware(DBHandler.CreateMindMap), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "PUT /api/sets/{setID}/mindmaps/{mindMapID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateMindMapByID), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "DELETE /api/sets/{setID}/mindmaps/{mindMapID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteMindMapByID), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "PUT /api/sets/{setID}/mindmaps/{mindMapID}/connections", Handler: DBHandler.UpdateMindMapConnections, Scope: models.ScopeWriteMindMaps},
+		{Pattern: "PUT /api/sets/{setID}/mindmaps/{mindMapID}/layouts", Handler: DBHandler.UpdateMindMapLayouts, Scope: models.ScopeWriteMindMaps},
+		{Pattern: "PATCH /api/sets/{setID}/mindmaps/{mindMapID}/graph", Handler: middleware.SyncUserMiddleware(DBHandler.PatchMindMapGraph), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/ops", Handler: DBHandler.GetMindMapOps, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/events", Handler: DBHandler.StreamMindMapEvents, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/presence", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateMindMapPresence), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/nodes", Handler: DBHandler.GetMindMapNodes, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/nodes", Handler: middleware.SyncUserMiddleware(DBHandler.CreateMindMapNode), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "PUT /api/sets/{setID}/mindmaps/{mindMapID}/nodes/{nodeID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateMindMapNode), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "DELETE /api/sets/{setID}/mindmaps/{mindMapID}/nodes/{nodeID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteMindMapNode), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots", Handler: DBHandler.GetMindMapSnapshots, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/diff", Handler: DBHandler.DiffMindMapSnapshots, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/{snapshotID}", Handler: DBHandler.GetMindMapSnapshot, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/snapshots/{snapshotID}/restore", Handler: middleware.SyncUserMiddleware(DBHandler.RestoreMindMapSnapshot), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/analysis", Handler: DBHandler.GetMindMapAnalysis, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/path", Handler: DBHandler.GetMindMapPath, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/path/prompts", Handler: DBHandler.GetMindMapPathPrompts, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/path/explanations", Handler: middleware.SyncUserMiddleware(DBHandler.CreateMindMapPathExplanation), Scope: models.ScopeStudy},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/path/explanations", Handler: middleware.SyncUserMiddleware(DBHandler.GetMindMapPathExplanations), Scope: models.ScopeStudy},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/quiz", Handler: DBHandler.GetMindMapQuiz, Auth: middleware.AuthOptional, Scope: models.ScopeReadMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/quiz/answers", Handler: middleware.SyncUserMiddleware(DBHandler.GradeMindMapQuiz), Scope: models.ScopeStudy},
+		{Pattern: "GET /api/sets/{setID}/mindmaps/{mindMapID}/quiz/results", Handler: middleware.SyncUserMiddleware(DBHandler.GetMindMapQuizResults), Scope: models.ScopeStudy},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/{mindMapID}/fork", Handler: middleware.SyncUserMiddleware(DBHandler.ForkMindMap), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/templates", Handler: middleware.SyncUserMiddleware(DBHandler.CreateMindMapFromTemplate), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "POST /api/sets/{setID}/mindmaps/generate", Handler: middleware.SyncUserMiddleware(DBHandler.GenerateMindMap), Scope: models.ScopeWriteMindMaps},
+		{Pattern: "GET /api/mindmaps/templates", Handler: DBHandler.GetMindMapTemplates, Scope: models.ScopeReadMindMaps},
+
+		// Relationship types
+		{Pattern: "GET /api/relationship-types", Handler: DBHandler.GetRelationshipTypes, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "GET /api/sets/{setID}/relationship-types", Handler: DBHandler.GetSetRelationshipTypes, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets/{setID}/relationship-types", Handler: middleware.SyncUserMiddleware(DBHandler.CreateRelationshipType), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/relationship-types/{typeKey}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteRelationshipType), Scope: models.ScopeWriteSets},
+
+		// Concepts
+		{Pattern: "GET /api/sets/{setID}/concepts", Handler: DBHandler.GetConceptsForSet, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "GET /api/sets/{setID}/concepts/flashcards", Handler: DBHandler.GetFlashcardsByConcept, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets/{setID}/concepts", Handler: middleware.SyncUserMiddleware(DBHandler.CreateConcept), Scope: models.ScopeWriteSets},
+		{Pattern: "PUT /api/sets/{setID}/concepts/{conceptID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateConcept), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/concepts/{conceptID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteConcept), Scope: models.ScopeWriteSets},
+		{Pattern: "POST /api/sets/{setID}/concepts/{conceptID}/flashcards", Handler: middleware.SyncUserMiddleware(DBHandler.AssignFlashcardsToConcept), Scope: models.ScopeWriteSets},
+
+		// Sections
+		{Pattern: "GET /api/sets/{setID}/sections", Handler: DBHandler.GetSectionsForSet, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets/{setID}/sections", Handler: middleware.SyncUserMiddleware(DBHandler.CreateSection), Scope: models.ScopeWriteSets},
+		{Pattern: "PUT /api/sets/{setID}/sections/{sectionID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateSection), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/sections/{sectionID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteSection), Scope: models.ScopeWriteSets},
+
+		// Study
+		{Pattern: "GET /api/sets/{setID}/study", Handler: middleware.SyncUserMiddleware(DBHandler.GetStudyQueue), Scope: models.ScopeStudy},
+		{Pattern: "POST /api/sets/{setID}/study/reviews", Handler: middleware.SyncUserMiddleware(DBHandler.RecordReview), Scope: models.ScopeStudy},
+		{Pattern: "GET /api/sets/{setID}/study/progress", Handler: middleware.SyncUserMiddleware(DBHandler.GetStudyProgress), Scope: models.ScopeStudy},
+		{Pattern: "GET /api/sets/{setID}/quiz", Handler: DBHandler.GetSetQuiz, Auth: middleware.AuthOptional, Scope: models.ScopeStudy},
+		{Pattern: "GET /api/study", Handler: middleware.SyncUserMiddleware(DBHandler.GetStudyOverview), Scope: models.ScopeStudy},
+
+		// Classrooms
+		{Pattern: "GET /api/classrooms", Handler: middleware.SyncUserMiddleware(DBHandler.GetClassrooms), Scope: models.ScopeClassrooms},
+		{Pattern: "POST /api/classrooms", Handler: middleware.SyncUserMiddleware(DBHandler.CreateClassroom), Scope: models.ScopeClassrooms},
+		{Pattern: "POST /api/classrooms/join", Handler: middleware.SyncUserMiddleware(DBHandler.JoinClassroom), Scope: models.ScopeClassrooms},
+		{Pattern: "GET /api/classrooms/{classroomID}", Handler: middleware.SyncUserMiddleware(DBHandler.GetClassroom), Scope: models.ScopeClassrooms},
+		{Pattern: "PUT /api/classrooms/{classroomID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateClassroom), Scope: models.ScopeClassrooms},
+		{Pattern: "DELETE /api/classrooms/{classroomID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteClassroom), Scope: models.ScopeClassrooms},
+		{Pattern: "POST /api/classrooms/{classroomID}/teachers", Handler: middleware.SyncUserMiddleware(DBHandler.AddClassroomTeacher), Scope: models.ScopeClassrooms},
+		{Pattern: "DELETE /api/classrooms/{classroomID}/members/{nickname}", Handler: middleware.SyncUserMiddleware(DBHandler.RemoveClassroomMember), Scope: models.ScopeClassrooms},
+		{Pattern: "POST /api/classrooms/{classroomID}/assignments", Handler: middleware.SyncUserMiddleware(DBHandler.CreateAssignment), Scope: models.ScopeClassrooms},
+		{Pattern: "PUT /api/classrooms/{classroomID}/assignments/{assignmentID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateAssignment), Scope: models.ScopeClassrooms},
+		{Pattern: "DELETE /api/classrooms/{classroomID}/assignments/{assignmentID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteAssignment), Scope: models.ScopeClassrooms},
+		{Pattern: "GET /api/classrooms/{classroomID}/dashboard", Handler: middleware.SyncUserMiddleware(DBHandler.GetClassroomDashboard), Scope: models.ScopeClassrooms},
+
+		// Organizations
+		{Pattern: "GET /api/orgs", Handler: middleware.SyncUserMiddleware(DBHandler.GetOrganizations), Scope: models.ScopeOrganizations},
+		{Pattern: "POST /api/orgs", Handler: middleware.SyncUserMiddleware(DBHandler.CreateOrganization), Scope: models.ScopeOrganizations},
+		{Pattern: "GET /api/orgs/{orgID}", Handler: middleware.SyncUserMiddleware(DBHandler.GetOrganization), Scope: models.ScopeOrganizations},
+		{Pattern: "PUT /api/orgs/{orgID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateOrganization), Scope: models.ScopeOrganizations},
+		{Pattern: "DELETE /api/orgs/{orgID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteOrganization), Scope: models.ScopeOrganizations},
+		{Pattern: "PUT /api/orgs/{orgID}/members/{nickname}", Handler: middleware.SyncUserMiddleware(DBHandler.SetOrganizationMember), Scope: models.ScopeOrganizations},
+		{Pattern: "DELETE /api/orgs/{orgID}/members/{nickname}", Handler: middleware.SyncUserMiddleware(DBHandler.RemoveOrganizationMember), Scope: models.ScopeOrganizations},
+		{Pattern: "GET /api/orgs/{orgID}/sets", Handler: middleware.SyncUserMiddleware(DBHandler.GetOrganizationSets), Scope: models.ScopeOrganizations},
+		{Pattern: "GET /api/orgs/{orgID}/mindmaps", Handler: middleware.SyncUserMiddleware(DBHandler.GetOrganizationMindMaps), Scope: models.ScopeOrganizations},
+		{Pattern: "GET /api/orgs/{orgID}/search", Handler: middleware.SyncUserMiddleware(DBHandler.SearchOrganization), Scope: models.ScopeOrganizations},
+
+		// Current user
+		{Pattern: "GET /api/me", Handler: middleware.SyncUserMiddleware(DBHandler.GetMe), Scope: models.ScopeReadProfile},
+		{Pattern: "PATCH /api/me", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateMe), Scope: models.ScopeWriteProfile},
+
+		// Account data
+		{Pattern: "GET /api/me/exports", Handler: middleware.SyncUserMiddleware(DBHandler.GetDataExports), Scope: models.ScopeReadProfile},
+		{Pattern: "POST /api/me/exports", Handler: middleware.SyncUserMiddleware(DBHandler.CreateDataExport), Scope: models.ScopeWriteProfile},
+		{Pattern: "GET /api/me/exports/{exportID}/download", Handler: middleware.SyncUserMiddleware(DBHandler.DownloadDataExport), Scope: models.ScopeReadProfile},
+		{Pattern: "POST /api/me/deletion", Handler: middleware.SyncUserMiddleware(DBHandler.ScheduleAccountDeletion), Scope: models.ScopeWriteProfile},
+		{Pattern: "DELETE /api/me/deletion", Handler: middleware.SyncUserMiddleware(DBHandler.CancelAccountDeletion), Scope: models.ScopeWriteProfile},
+
+		// Personal access tokens
+		{Pattern: "GET /api/tokens", Handler: middleware.SyncUserMiddleware(DBHandler.GetAccessTokens), Scope: models.ScopeReadProfile},
+		{Pattern: "POST /api/tokens", Handler: middleware.SyncUserMiddleware(DBHandler.CreateAccessToken), Scope: models.ScopeWriteProfile},
+		{Pattern: "DELETE /api/tokens/{tokenID}", Handler: middleware.SyncUserMiddleware(DBHandler.RevokeAccessToken), Scope: models.ScopeWriteProfile},
+
+		// Blocks
+		{Pattern: "GET /api/blocks/leaderboard/{setID}", Handler: DBHandler.GetBlocksLeaderboard, Scope: models.ScopeStudy},
+		{Pattern: "POST /api/blocks/score/{setID}", Handler: DBHandler.CreateBlockScore, Scope: models.ScopeStudy},
+
+		// Flashcard
+		{Pattern: "POST /api/sets/{setID}/flashcards/", Handler: middleware.SyncUserMiddleware(DBHandler.CreateFlashCard), Scope: models.ScopeWriteSets},
+		{Pattern: "GET /api/sets/{setID}/flashcards/{flashcardID}", Handler: middleware.SyncUserMiddleware(DBHandler.GetFlashcardByID), Scope: models.ScopeReadSets},
+		{Pattern: "GET /api/sets/{setID}/flashcards", Handler: DBHandler.GetFlashcardsForSet, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "PUT /api/sets/{setID}/flashcards/order", Handler: middleware.SyncUserMiddleware(DBHandler.ReorderFlashcards), Scope: models.ScopeWriteSets},
+		{Pattern: "GET /api/sets/{setID}/flashcards/{flashcardID}/render", Handler: DBHandler.RenderFlashcard, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/render", Handler: DBHandler.RenderPreview, Scope: models.ScopeReadSets},
+
+		// Share links
+		{Pattern: "GET /api/sets/{setID}/share-links", Handler: middleware.SyncUserMiddleware(DBHandler.GetShareLinks), Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets/{setID}/share-links", Handler: middleware.SyncUserMiddleware(DBHandler.CreateShareLink), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/share-links/{linkID}", Handler: middleware.SyncUserMiddleware(DBHandler.RevokeShareLink), Scope: models.ScopeWriteSets},
+		{Pattern: "GET /api/share/{token}", Handler: DBHandler.OpenShareLink, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+
+		// Media
+		{Pattern: "GET /api/sets/{setID}/flashcards/{flashcardID}/media", Handler: DBHandler.GetFlashcardMedia, Auth: middleware.AuthOptional, Scope: models.ScopeReadSets},
+		{Pattern: "POST /api/sets/{setID}/flashcards/{flashcardID}/media", Handler: middleware.SyncUserMiddleware(DBHandler.UploadFlashcardMedia), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/flashcards/{flashcardID}/media/{mediaID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteFlashcardMedia), Scope: models.ScopeWriteSets},
+		// Media URLs are signed, and <img> and <audio> tags can't send a bearer token
+		{Pattern: "GET /api/media/{mediaID}", Handler: DBHandler.ServeMedia, Auth: middleware.AuthNone},
+		{Pattern: "PUT /api/sets/{setID}/flashcards/{flashcardID}", Handler: middleware.SyncUserMiddleware(DBHandler.UpdateFlashCardByID), Scope: models.ScopeWriteSets},
+		{Pattern: "DELETE /api/sets/{setID}/flashcards/{flashcardID}", Handler: middleware.SyncUserMiddleware(DBHandler.DeleteFlashCardByID), Scope: models.ScopeWriteSets},
+	}
+
+	authenticator, err := middleware.NewAuthenticator()
+	if err != nil {
+		log.Fatalf("Failed to set up authentication: %v", err)
+	}
+	if issuer, ok := authenticator.(*middleware.DevIssuer); ok {
+		routes = append(routes, middleware.Route{Pattern: "POST /dev/token", Handler: issuer.ServeToken, Auth: middleware.AuthNone})
+	}
+	for _, route := range routes {
+		mux.HandleFunc(route.Pattern, route.Handler)
+	}
+	authMiddleware := middleware.EnsureValidToken(mux, authenticator, routes)
 
 	// Configure CORS with specific options
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "https://thenodebook.vercel.app", "https://www.mindthred.com"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With", "Accept", "Origin"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With", "Accept", "Origin", "X-Share-Token"},
 		AllowCredentials: true,
 		MaxAge:           86400,
 	}).Handler(authMiddleware(mux))
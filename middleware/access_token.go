@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewpaige1/nodebook-api/config"
+	"github.com/andrewpaige1/nodebook-api/models"
+	"github.com/andrewpaige1/nodebook-api/utils"
+
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+)
+
+// lastUsedResolution limits how often a token's LastUsedAt is written, so a
+// busy script doesn't update the row on every request
+const lastUsedResolution = time.Minute
+
+// bearerAccessToken returns the personal access token in the Authorization
+// header, if the bearer token is one.
+func bearerAccessToken(r *http.Request) (string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || !strings.HasPrefix(token, utils.AccessTokenPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// authenticateAccessToken checks a personal access token and returns the
+// request with the same claims a JWT for its user would have produced, limited
+// to the token's scopes.
+func authenticateAccessToken(r *http.Request, token string) (*http.Request, error) {
+	var pat models.PersonalAccessToken
+	if err := config.Database.Preload("User").Where("token_hash = ?", utils.HashAccessToken(token)).First(&pat).Error; err != nil {
+		return nil, errors.New("unknown access token")
+	}
+	now := time.Now()
+	if !pat.Live(now) {
+		return nil, errors.New("access token is revoked or expired")
+	}
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) >= lastUsedResolution {
+		if err := config.Database.Model(&pat).UpdateColumn("last_used_at", now).Error; err != nil {
+			log.Printf("Failed to record use of access token %s: %v", pat.PublicID, err)
+		}
+	}
+
+	claims := &validator.ValidatedClaims{
+		RegisteredClaims: validator.RegisteredClaims{
+			Issuer:  utils.AccessTokenIssuer,
+			Subject: pat.User.Auth0ID,
+			ID:      pat.PublicID,
+		},
+		CustomClaims: &CustomClaims{
+			Scope:    pat.Scopes,
+			Nickname: pat.User.Nickname,
+		},
+	}
+	return r.WithContext(context.WithValue(r.Context(), jwtmiddleware.ContextKey{}, claims)), nil
+}
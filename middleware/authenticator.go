@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-jwt-middleware/v2/jwks"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	jose "gopkg.in/go-jose/go-jose.v2"
+)
+
+// Authenticator checks a bearer JWT and returns its *validator.ValidatedClaims,
+// with CustomClaims filled in.
+type Authenticator interface {
+	ValidateToken(ctx context.Context, token string) (interface{}, error)
+}
+
+// NewAuthenticator builds the identity provider selected by AUTH_PROVIDER:
+// "oidc" (the default) trusts any OpenID Connect issuer, Auth0 included;
+// "jwks-file" trusts the keys in AUTH_JWKS_FILE, for running offline; "dev"
+// issues its own tokens from /dev/token.
+func NewAuthenticator() (Authenticator, error) {
+	audience := firstEnv("AUTH_AUDIENCE", "AUTH0_AUDIENCE")
+	issuer := os.Getenv("AUTH_ISSUER_URL")
+	if issuer == "" && os.Getenv("AUTH0_DOMAIN") != "" {
+		issuer = "https://" + os.Getenv("AUTH0_DOMAIN") + "/"
+	}
+
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", "oidc":
+		if issuer == "" || audience == "" {
+			return nil, fmt.Errorf("AUTH_ISSUER_URL (or AUTH0_DOMAIN) and AUTH_AUDIENCE are required for the oidc provider")
+		}
+		return NewOIDCAuthenticator(issuer, audience, os.Getenv("AUTH_JWKS_URL"))
+	case "jwks-file":
+		if issuer == "" || audience == "" || os.Getenv("AUTH_JWKS_FILE") == "" {
+			return nil, fmt.Errorf("AUTH_JWKS_FILE, AUTH_ISSUER_URL and AUTH_AUDIENCE are required for the jwks-file provider")
+		}
+		return NewJWKSFileAuthenticator(os.Getenv("AUTH_JWKS_FILE"), issuer, audience)
+	case "dev":
+		// Anyone can mint a token from a dev issuer
+		if os.Getenv("RAILWAY_ENVIRONMENT_NAME") != "" {
+			return nil, fmt.Errorf("the dev auth provider can't be used in a deployed environment")
+		}
+		if issuer == "" {
+			issuer = "nodebook-dev"
+		}
+		if audience == "" {
+			audience = "nodebook-api"
+		}
+		return NewDevIssuer(os.Getenv("AUTH_DEV_ALGORITHM"), []byte(os.Getenv("AUTH_DEV_SECRET")), issuer, audience)
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q", provider)
+	}
+}
+
+// NewOIDCAuthenticator validates RS256 tokens from an OpenID Connect issuer,
+// fetching its signing keys from the JWKS its discovery document points to,
+// or from jwksURL when that's set.
+func NewOIDCAuthenticator(issuer, audience, jwksURL string) (Authenticator, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the issuer url: %w", err)
+	}
+	var opts []interface{}
+	if jwksURL != "" {
+		customURL, err := url.Parse(jwksURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the jwks url: %w", err)
+		}
+		opts = append(opts, jwks.WithCustomJWKSURI(customURL))
+	}
+	provider := jwks.NewCachingProvider(issuerURL, 5*time.Minute, opts...)
+	return newValidator(provider.KeyFunc, validator.RS256, issuerURL.String(), audience)
+}
+
+// NewJWKSFileAuthenticator validates RS256 tokens against keys read once from
+// a JWKS file, so tokens can be checked without reaching the issuer.
+func NewJWKSFileAuthenticator(path, issuer, audience string) (Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the jwks file: %w", err)
+	}
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse the jwks file: %w", err)
+	}
+	if len(keys.Keys) == 0 {
+		return nil, fmt.Errorf("the jwks file %s has no keys", path)
+	}
+	keyFunc := func(context.Context) (interface{}, error) {
+		return &keys, nil
+	}
+	return newValidator(keyFunc, validator.RS256, issuer, audience)
+}
+
+func newValidator(keyFunc func(context.Context) (interface{}, error), algorithm validator.SignatureAlgorithm, issuer, audience string) (*validator.Validator, error) {
+	return validator.New(
+		keyFunc,
+		algorithm,
+		issuer,
+		[]string{audience},
+		validator.WithCustomClaims(
+			func() validator.CustomClaims {
+				return &CustomClaims{}
+			},
+		),
+		validator.WithAllowedClockSkew(time.Minute),
+	)
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	jose "gopkg.in/go-jose/go-jose.v2"
+	"gopkg.in/go-jose/go-jose.v2/jwt"
+
+	"github.com/andrewpaige1/nodebook-api/models"
+)
+
+const (
+	devTokenLifetime    = time.Hour
+	maxDevTokenLifetime = 30 * 24 * time.Hour
+)
+
+// DevIssuer is a local identity provider for development and tests. It signs
+// its own tokens, handed out by ServeToken to anyone who asks, so the API can
+// run without reaching a real issuer.
+type DevIssuer struct {
+	*validator.Validator
+	signer   jose.Signer
+	issuer   string
+	audience string
+}
+
+// NewDevIssuer signs with algorithm, HS256 (the default) or RS256. HS256 uses
+// secret, or a random key when it's empty; RS256 always generates a key.
+// Either way a random key means tokens stop working on restart.
+func NewDevIssuer(algorithm string, secret []byte, issuer, audience string) (*DevIssuer, error) {
+	var signingKey jose.SigningKey
+	var verificationKey interface{}
+	switch algorithm {
+	case "", "HS256":
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		} else if len(secret) < 32 {
+			return nil, fmt.Errorf("AUTH_DEV_SECRET must be at least 32 bytes for HS256")
+		}
+		signingKey = jose.SigningKey{Algorithm: jose.HS256, Key: secret}
+		verificationKey = secret
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = jose.SigningKey{Algorithm: jose.RS256, Key: key}
+		verificationKey = &key.PublicKey
+	default:
+		return nil, fmt.Errorf("unknown AUTH_DEV_ALGORITHM %q, expected HS256 or RS256", algorithm)
+	}
+
+	signer, err := jose.NewSigner(signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return nil, err
+	}
+	keyFunc := func(context.Context) (interface{}, error) {
+		return verificationKey, nil
+	}
+	v, err := newValidator(keyFunc, validator.SignatureAlgorithm(signingKey.Algorithm), issuer, audience)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Warning: using the %s dev auth issuer; anyone can get a token from POST /dev/token", signingKey.Algorithm)
+	return &DevIssuer{Validator: v, signer: signer, issuer: issuer, audience: audience}, nil
+}
+
+// Issue signs a token for subject carrying the given nickname and space
+// separated scopes.
+func (d *DevIssuer) Issue(subject, nickname, scope string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+	return jwt.Signed(d.signer).
+		Claims(jwt.Claims{
+			Issuer:   d.issuer,
+			Subject:  subject,
+			Audience: jwt.Audience{d.audience},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(lifetime)),
+		}).
+		Claims(CustomClaims{Scope: scope, Nickname: nickname}).
+		CompactSerialize()
+}
+
+// POST /dev/token
+//
+// Every field is optional: the default is a token for "dev|local" with every
+// scope, valid for an hour.
+func (d *DevIssuer) ServeToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subject   string   `json:"Subject"`
+		Nickname  string   `json:"Nickname"`
+		Scopes    []string `json:"Scopes"`
+		ExpiresIn int      `json:"ExpiresIn"` // Seconds
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Subject == "" {
+		req.Subject = "dev|local"
+	}
+	if req.Nickname == "" {
+		req.Nickname = "dev"
+	}
+	if req.Scopes == nil {
+		req.Scopes = models.TokenScopes
+	}
+	lifetime := devTokenLifetime
+	if req.ExpiresIn > 0 {
+		lifetime = min(time.Duration(req.ExpiresIn)*time.Second, maxDevTokenLifetime)
+	}
+
+	token, err := d.Issue(req.Subject, req.Nickname, strings.Join(req.Scopes, " "), lifetime)
+	if err != nil {
+		http.Error(w, "Failed to sign token", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(lifetime.Seconds()),
+	})
+}
@@ -2,24 +2,25 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
-	"time"
 
 	"slices"
 
 	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
-	"github.com/auth0/go-jwt-middleware/v2/jwks"
 	"github.com/auth0/go-jwt-middleware/v2/validator"
+
+	"github.com/andrewpaige1/nodebook-api/utils"
 )
 
 // CustomClaims contains custom data we want from the token.
 type CustomClaims struct {
-	Scope    string `json:"scope"`
-	Nickname string `json:"https://api.mindthred.com/nickname"`
+	Scope           string `json:"scope"`
+	Nickname        string `json:"https://api.mindthred.com/nickname"`
+	AuthorizedParty string `json:"azp,omitempty"` // Client ID the token was issued to
 }
 
 // Validate does nothing for this example, but we need
@@ -28,29 +29,36 @@ func (c CustomClaims) Validate(ctx context.Context) error {
 	return nil
 }
 
-// EnsureValidToken is a middleware that will check the validity of our JWT.
-func EnsureValidToken() func(next http.Handler) http.Handler {
-	issuerURL, err := url.Parse("https://" + os.Getenv("AUTH0_DOMAIN") + "/")
-	if err != nil {
-		log.Fatalf("Failed to parse the issuer url: %v", err)
-	}
+// AuthPolicy says whether a route needs a signed-in caller.
+type AuthPolicy string
 
-	provider := jwks.NewCachingProvider(issuerURL, 5*time.Minute)
-
-	jwtValidator, err := validator.New(
-		provider.KeyFunc,
-		validator.RS256,
-		issuerURL.String(),
-		[]string{os.Getenv("AUTH0_AUDIENCE")},
-		validator.WithCustomClaims(
-			func() validator.CustomClaims {
-				return &CustomClaims{}
-			},
-		),
-		validator.WithAllowedClockSkew(time.Minute),
-	)
-	if err != nil {
-		log.Fatalf("Failed to set up the jwt validator")
+const (
+	AuthRequired AuthPolicy = "required" // 401 without a valid token
+	AuthOptional AuthPolicy = "optional" // Anonymous without a token, 401 with an invalid one
+	AuthNone     AuthPolicy = "none"     // The token is never read
+)
+
+// Route is one entry in the API's route table.
+type Route struct {
+	Pattern string // ServeMux pattern, method included
+	Handler http.HandlerFunc
+	Auth    AuthPolicy // AuthRequired when empty
+	Scope   string     // Scope the caller's token must carry; see requireScope for who is checked
+}
+
+// EnsureValidToken is a middleware that will check the validity of our JWT
+// with authenticator, or of a personal access token sent as the bearer token
+// instead, and that the token carries the scope its route needs. Each request
+// is matched to its route by the mux pattern it was registered with.
+//
+// Every token is held to its scopes, except JWTs issued to a client listed in
+// AUTH_SCOPE_EXEMPT_CLIENTS, a comma-separated list of client IDs for
+// first-party frontends whose tokens don't carry the API's scopes yet.
+func EnsureValidToken(mux *http.ServeMux, authenticator Authenticator, routes []Route) func(next http.Handler) http.Handler {
+	exemptClients := scopeExemptClients(os.Getenv("AUTH_SCOPE_EXEMPT_CLIENTS"))
+	byPattern := make(map[string]Route, len(routes))
+	for _, route := range routes {
+		byPattern[route.Pattern] = route
 	}
 
 	errorHandler := func(w http.ResponseWriter, r *http.Request, err error) {
@@ -64,15 +72,87 @@ func EnsureValidToken() func(next http.Handler) http.Handler {
 	}
 
 	middleware := jwtmiddleware.New(
-		jwtValidator.ValidateToken,
+		authenticator.ValidateToken,
 		jwtmiddleware.WithErrorHandler(errorHandler),
 	)
+	optionalMiddleware := jwtmiddleware.New(
+		authenticator.ValidateToken,
+		jwtmiddleware.WithErrorHandler(errorHandler),
+		jwtmiddleware.WithCredentialsOptional(true),
+	)
 
 	return func(next http.Handler) http.Handler {
-		return middleware.CheckJWT(next)
+		scoped := requireScope(mux, byPattern, exemptClients, next)
+		required := middleware.CheckJWT(scoped)
+		optional := optionalMiddleware.CheckJWT(scoped)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			policy := byPattern[pattern].Auth
+			if token, ok := bearerAccessToken(r); ok && policy != AuthNone {
+				authenticated, err := authenticateAccessToken(r, token)
+				if err != nil {
+					log.Printf("Encountered error while validating access token: %v", err)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"message":"Failed to validate access token."}`))
+					return
+				}
+				scoped.ServeHTTP(w, authenticated)
+				return
+			}
+			switch policy {
+			case AuthNone:
+				next.ServeHTTP(w, r)
+			case AuthOptional:
+				optional.ServeHTTP(w, r)
+			default:
+				required.ServeHTTP(w, r)
+			}
+		})
 	}
 }
 
+// scopeExemptClients parses a comma-separated list of client IDs.
+func scopeExemptClients(list string) map[string]bool {
+	clients := make(map[string]bool)
+	for _, client := range strings.Split(list, ",") {
+		if client = strings.TrimSpace(client); client != "" {
+			clients[client] = true
+		}
+	}
+	return clients
+}
+
+// requireScope rejects callers whose token lacks the scope of the route
+// they're calling with a 403 naming it. Anonymous callers aren't checked, nor
+// are JWTs issued to one of exemptClients.
+func requireScope(mux *http.ServeMux, routes map[string]Route, exemptClients map[string]bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		scope := routes[pattern].Scope
+		claims, ok := r.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
+		if scope == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		custom, ok := claims.CustomClaims.(*CustomClaims)
+		if ok && claims.RegisteredClaims.Issuer != utils.AccessTokenIssuer && exemptClients[custom.AuthorizedParty] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok || !custom.HasScope(scope) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Insufficient scope. This endpoint requires " + scope + ".",
+				"scope":   scope,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // HasScope checks whether our claims have a specific scope.
 func (c CustomClaims) HasScope(expectedScope string) bool {
 	result := strings.Split(c.Scope, " ")
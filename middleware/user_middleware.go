@@ -58,11 +58,12 @@ func SyncUserMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			}
 			log.Printf("Created new user: %s\n", user.Nickname)
 		} else {
-			// User exists, update nickname only if non-empty, changed, and not used by another user
-			if auth0Payload.Nickname != "" && user.Nickname != auth0Payload.Nickname {
+			// User exists, update nickname only if non-empty, changed, not chosen by
+			// the user through /api/me, and not used by another user
+			if auth0Payload.Nickname != "" && user.Nickname != auth0Payload.Nickname && !user.NicknameCustomized {
 				var count int64
 				config.Database.Model(&models.User{}).
-					Where("nickname = ? AND id != ?", auth0Payload.Nickname, user.ID).
+					Where("LOWER(nickname) = LOWER(?) AND id != ?", auth0Payload.Nickname, user.ID).
 					Count(&count)
 				if count == 0 {
 					user.Nickname = auth0Payload.Nickname
@@ -7,7 +7,7 @@ import (
 type BlocksScore struct {
 	ID              uint         `gorm:"primaryKey"`
 	UserID          uint         `gorm:"not null;index"`
-	User            User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	User            User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	FlashcardSetID  uint         `gorm:"not null;index"`
 	FlashcardSet    FlashcardSet `gorm:"foreignKey:FlashcardSetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	TimeSeconds     int          `gorm:"not null"`
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ClassroomTeacher = "teacher"
+	ClassroomStudent = "student"
+)
+
+// Classroom groups students under one or more teachers and assigns them sets to study
+type Classroom struct {
+	gorm.Model
+	PublicID    string `gorm:"size:100;uniqueIndex"`
+	Name        string `gorm:"not null;size:100"`
+	Description string `gorm:"size:1000"`
+	OwnerID     uint   `gorm:"not null;index"`
+	JoinCode    string `gorm:"not null;size:12;uniqueIndex"` // Students join with this; only shown to teachers
+
+	Owner User `gorm:"foreignKey:OwnerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// ClassroomMember is a teacher or student in a classroom. The owner is also a teacher member.
+type ClassroomMember struct {
+	gorm.Model
+	ClassroomID uint   `gorm:"not null;uniqueIndex:idx_classroom_member"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_classroom_member;index"`
+	Role        string `gorm:"not null;size:10"`
+
+	Classroom Classroom `gorm:"foreignKey:ClassroomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// ClassroomAssignment assigns a set to every student in a classroom
+type ClassroomAssignment struct {
+	gorm.Model
+	PublicID    string     `gorm:"size:100;uniqueIndex"`
+	ClassroomID uint       `gorm:"not null;uniqueIndex:idx_classroom_assignment"`
+	SetID       uint       `gorm:"not null;uniqueIndex:idx_classroom_assignment;index"`
+	DueAt       *time.Time `gorm:"default:null"`
+
+	Classroom    Classroom    `gorm:"foreignKey:ClassroomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// Concept is a learning goal within a set that flashcards can be grouped under
+type Concept struct {
+	gorm.Model
+	SetID       uint   `gorm:"not null;index"`
+	PublicID    string `gorm:"size:100;uniqueIndex"`
+	Name        string `gorm:"not null;size:200"`
+	Description string `gorm:"size:1000"`
+	Position    int    `gorm:"not null;default:0"`
+
+	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
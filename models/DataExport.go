@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a zip of everything a user has stored, built in the background
+// and downloadable until it expires
+type DataExport struct {
+	gorm.Model
+	PublicID    string     `gorm:"size:100;uniqueIndex"`
+	UserID      uint       `gorm:"not null;index"`
+	Status      string     `gorm:"not null;size:10"`
+	Size        int64      `gorm:"not null;default:0"`
+	StorageKey  string     `gorm:"size:300" json:"-"`
+	CompletedAt *time.Time `gorm:"default:null"`
+	ExpiresAt   *time.Time `gorm:"default:null;index"` // Set once ready; the file is removed after this
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	MediaImage = "image"
+	MediaAudio = "audio"
+
+	MediaSideTerm     = "term"
+	MediaSideSolution = "solution"
+)
+
+// FlashcardMedia is an image or audio file attached to one side of a flashcard
+type FlashcardMedia struct {
+	gorm.Model
+	PublicID     string `gorm:"size:100;uniqueIndex"`
+	FlashcardID  uint   `gorm:"not null;index"`
+	Side         string `gorm:"not null;size:20"`
+	Kind         string `gorm:"not null;size:20"`
+	ContentType  string `gorm:"not null;size:100"`
+	Size         int64  `gorm:"not null"`
+	Width        int    `gorm:"default:0"` // Images only
+	Height       int    `gorm:"default:0"`
+	StorageKey   string `gorm:"not null;size:300" json:"-"`
+	ThumbnailKey string `gorm:"size:300" json:"-"` // Empty when no thumbnail could be made
+
+	Flashcard Flashcard `gorm:"foreignKey:FlashcardID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
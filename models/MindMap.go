@@ -5,12 +5,17 @@ import "gorm.io/gorm"
 // MindMap represents a single mind map for a flashcard set
 type MindMap struct {
 	gorm.Model
-	Title    string `gorm:"not null;size:100"`
-	SetID    uint   `gorm:"not null"` // References FlashcardSet
-	UserID   uint   `gorm:"not null"` // References User
-	IsPublic bool   `gorm:"default:false"`
-	PublicID string `gorm:"size:100;uniqueIndex"`
+	Title  string `gorm:"not null;size:100"`
+	SetID  uint   `gorm:"not null"` // References FlashcardSet
+	UserID uint   `gorm:"not null"` // References User
+	// Organization that owns the map, always the same as its set's
+	OrganizationID *uint  `gorm:"index"`
+	IsPublic       bool   `gorm:"default:false"`
+	PublicID       string `gorm:"size:100;uniqueIndex"`
+	Seq            uint   `gorm:"not null;default:0"` // Sequence number of the last applied MindMapOperation
 
 	// Relationships between flashcards
 	Connections []MindMapConnection `gorm:"foreignKey:MindMapID"`
+	// Labels, groups and notes that aren't flashcards
+	Nodes []MindMapNode `gorm:"foreignKey:MindMapID"`
 }
@@ -4,10 +4,14 @@ import "gorm.io/gorm"
 
 type MindMapConnection struct {
 	gorm.Model
-	MindMapID    uint   `gorm:"not null"`
-	SourceID     uint   `gorm:"not null"` // References Flashcard
-	TargetID     uint   `gorm:"not null"` // References Flashcard
-	Relationship string `gorm:"size:200"` // Describes how the cards are related
+	MindMapID        uint   `gorm:"not null"`
+	SourceID         uint   `gorm:"not null"`      // References Flashcard, 0 when SourceNodeID is set
+	TargetID         uint   `gorm:"not null"`      // References Flashcard, 0 when TargetNodeID is set
+	SourceNodeID     *uint  `gorm:"index"`         // References MindMapNode
+	TargetNodeID     *uint  `gorm:"index"`         // References MindMapNode
+	Relationship     string `gorm:"size:200"`      // Describes how the cards are related
+	RelationshipType string `gorm:"size:50;index"` // Key of a RelationshipType, empty when untyped
+	Note             string `gorm:"size:500"`
 
 	// References
 	MindMap MindMap   `gorm:"foreignKey:MindMapID" json:"-"`
@@ -11,4 +11,5 @@ type MindMapNodeLayout struct {
 	XPosition   float64 `gorm:"not null"`
 	YPosition   float64 `gorm:"not null"`
 	Data        string  `gorm:"not null;size:200"`
+	GroupID     *uint   `gorm:"index"` // Containing group MindMapNode, if any
 }
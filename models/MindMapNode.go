@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	MindMapNodeText  = "text"  // Free-text label or heading
+	MindMapNodeGroup = "group" // Frame that contains other nodes and cards
+	MindMapNodeNote  = "note"  // Annotation attached to the map
+)
+
+// MindMapNode is a node on a mind map that isn't a flashcard
+type MindMapNode struct {
+	gorm.Model
+	MindMapID uint    `gorm:"not null;index"`
+	PublicID  string  `gorm:"size:100;uniqueIndex"`
+	Kind      string  `gorm:"not null;size:20"`
+	Label     string  `gorm:"size:200"`
+	Body      string  `gorm:"size:2500"`
+	ParentID  *uint   `gorm:"index"` // Containing group node, if any
+	XPosition float64 `gorm:"not null"`
+	YPosition float64 `gorm:"not null"`
+	Width     float64 `gorm:"default:0"`
+	Height    float64 `gorm:"default:0"`
+
+	MindMap MindMap `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MindMapOperation is one incremental edit applied to a mind map's nodes or edges.
+// Seq is assigned by the server and increases by one per operation within a mind map.
+type MindMapOperation struct {
+	ID        uint      `gorm:"primaryKey"`
+	MindMapID uint      `gorm:"not null;uniqueIndex:idx_mind_map_operation_seq"`
+	Seq       uint      `gorm:"not null;uniqueIndex:idx_mind_map_operation_seq"`
+	UserID    uint      `gorm:"not null"`
+	ClientID  string    `gorm:"size:100;index"` // Lets a client resume without re-applying operations
+	ClientSeq uint      `gorm:"not null;default:0"`
+	Op        string    `gorm:"not null;size:20"`
+	Path      string    `gorm:"not null;size:200"`
+	Value     string    `gorm:"type:text"` // JSON encoded operation value
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+
+	MindMap MindMap `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MindMapPathExplanation is a learner's own account of how one flashcard in a
+// mind map leads to another, written in the path explanation study mode
+type MindMapPathExplanation struct {
+	ID              uint      `gorm:"primaryKey"`
+	PublicID        string    `gorm:"size:100;uniqueIndex"`
+	UserID          uint      `gorm:"not null;index"`
+	MindMapID       uint      `gorm:"not null;index"`
+	FromFlashcardID uint      `gorm:"not null"`
+	ToFlashcardID   uint      `gorm:"not null"`
+	Explanation     string    `gorm:"type:text;not null"`
+	StepsCovered    int       `gorm:"not null"` // Steps of the closest matching path the explanation names
+	TotalSteps      int       `gorm:"not null"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+
+	User          User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	MindMap       MindMap   `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	FromFlashcard Flashcard `gorm:"foreignKey:FromFlashcardID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ToFlashcard   Flashcard `gorm:"foreignKey:ToFlashcardID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// MindMapQuiz is a quiz handed out to a signed-in user, kept so its answers
+// are graded against the questions actually asked, and only once
+type MindMapQuiz struct {
+	ID        uint       `gorm:"primaryKey"`
+	PublicID  string     `gorm:"size:100;uniqueIndex"`
+	UserID    uint       `gorm:"not null;index"`
+	MindMapID uint       `gorm:"not null;index"`
+	QuizType  string     `gorm:"not null;size:30"`
+	Questions string     `gorm:"type:text" json:"-"` // JSON encoded questions: type, connection and hidden connections
+	GradedAt  *time.Time `gorm:"default:null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+
+	User    User    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	MindMap MindMap `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// MindMapQuizResult records a graded quiz generated from a mind map's connections
+type MindMapQuizResult struct {
+	ID             uint         `gorm:"primaryKey"`
+	UserID         uint         `gorm:"not null;index"`
+	User           User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	FlashcardSetID uint         `gorm:"not null;index"`
+	FlashcardSet   FlashcardSet `gorm:"foreignKey:FlashcardSetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	MindMapID      uint         `gorm:"not null;index"`
+	MindMap        MindMap      `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	QuizType       string       `gorm:"not null;size:30"`
+	CorrectAnswers int          `gorm:"not null"`
+	TotalQuestions int          `gorm:"not null"`
+	PlayedAt       time.Time    `gorm:"autoCreateTime"`
+}
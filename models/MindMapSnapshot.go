@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// MindMapSnapshot is the full graph of a mind map as it was after a save
+type MindMapSnapshot struct {
+	gorm.Model
+	MindMapID   uint   `gorm:"not null;index"`
+	UserID      uint   `gorm:"not null"`
+	Seq         uint   `gorm:"not null"`           // MindMap.Seq when the snapshot was taken
+	Reason      string `gorm:"not null;size:30"`   // What kind of save produced it
+	Connections string `gorm:"type:text" json:"-"` // JSON encoded []MindMapConnection
+	Layouts     string `gorm:"type:text" json:"-"` // JSON encoded []MindMapNodeLayout
+	Nodes       string `gorm:"type:text" json:"-"` // JSON encoded []MindMapNode
+
+	MindMap MindMap `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
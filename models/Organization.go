@@ -0,0 +1,44 @@
+package models
+
+import "gorm.io/gorm"
+
+// Organization roles, lowest first. Each role can do everything the ones before it can.
+const (
+	OrgViewer = "viewer" // Read every set and mind map in the organization
+	OrgEditor = "editor" // Create and edit them
+	OrgAdmin  = "admin"  // Delete them and manage members
+	OrgOwner  = "owner"  // Rename or delete the organization
+)
+
+// OrgRoleRank orders roles so they can be compared; unknown roles rank 0.
+func OrgRoleRank(role string) int {
+	switch role {
+	case OrgViewer:
+		return 1
+	case OrgEditor:
+		return 2
+	case OrgAdmin:
+		return 3
+	case OrgOwner:
+		return 4
+	}
+	return 0
+}
+
+// Organization is a workspace that owns sets and mind maps on behalf of its members
+type Organization struct {
+	gorm.Model
+	PublicID string `gorm:"size:100;uniqueIndex"`
+	Name     string `gorm:"not null;size:100"`
+}
+
+// OrganizationMember gives a user a role across every set and mind map in an organization
+type OrganizationMember struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"not null;uniqueIndex:idx_organization_member"`
+	UserID         uint   `gorm:"not null;uniqueIndex:idx_organization_member;index"`
+	Role           string `gorm:"not null;size:10"`
+
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	User         User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
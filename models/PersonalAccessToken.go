@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes a personal access token can be limited to. They use the same names
+// as the scope claim on a JWT.
+const (
+	ScopeReadSets      = "read:sets"
+	ScopeWriteSets     = "write:sets"
+	ScopeReadMindMaps  = "read:mindmaps"
+	ScopeWriteMindMaps = "write:mindmaps"
+	ScopeStudy         = "study"      // Study queues, reviews, quizzes and Blocks scores
+	ScopeClassrooms    = "classrooms" // Classrooms and their assignments
+	ScopeOrganizations = "orgs"       // Organizations and their members
+	ScopeReadProfile   = "read:profile"
+	ScopeWriteProfile  = "write:profile"
+)
+
+// TokenScopes lists every scope a personal access token may be granted
+var TokenScopes = []string{
+	ScopeReadSets, ScopeWriteSets,
+	ScopeReadMindMaps, ScopeWriteMindMaps,
+	ScopeStudy, ScopeClassrooms, ScopeOrganizations,
+	ScopeReadProfile, ScopeWriteProfile,
+}
+
+// PersonalAccessToken lets scripts and integrations call the API as a user
+// with a long-lived bearer token instead of a JWT
+type PersonalAccessToken struct {
+	gorm.Model
+	PublicID   string     `gorm:"size:100;uniqueIndex"`
+	UserID     uint       `gorm:"not null;index"`
+	Name       string     `gorm:"not null;size:100"`
+	TokenHash  string     `gorm:"not null;size:64;uniqueIndex" json:"-"` // SHA-256 of the token; the token itself is only shown once
+	Prefix     string     `gorm:"not null;size:12"`                      // Start of the token, so users can tell their tokens apart
+	Scopes     string     `gorm:"not null;size:500"`                     // Space separated, like a JWT's scope claim
+	ExpiresAt  *time.Time `gorm:"default:null"`
+	LastUsedAt *time.Time `gorm:"default:null"`
+	RevokedAt  *time.Time `gorm:"default:null"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Live reports whether the token is neither revoked nor expired at now.
+func (t *PersonalAccessToken) Live(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	RelationshipCausal        = "causal"         // Source brings about the target
+	RelationshipPartOf        = "part-of"        // Source is a component of the target
+	RelationshipExampleOf     = "example-of"     // Source is an instance of the target
+	RelationshipContrastsWith = "contrasts-with" // Source and target are opposed or compared
+	RelationshipRelated       = "related"        // Any other association
+)
+
+// RelationshipType is a named kind of mind map connection. The global
+// vocabulary is built in; sets can add their own types on top of it.
+type RelationshipType struct {
+	gorm.Model
+	SetID       uint   `gorm:"not null;uniqueIndex:idx_relationship_type_key"`
+	Key         string `gorm:"not null;size:50;uniqueIndex:idx_relationship_type_key"`
+	Name        string `gorm:"not null;size:100"`
+	Category    string `gorm:"not null;size:20"`
+	Directed    bool   `gorm:"not null"`
+	Aliases     string `gorm:"size:500"` // Comma-separated labels that map onto this type
+	Description string `gorm:"size:500"`
+
+	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DirectionForward = "forward" // Term -> Solution
+	DirectionReverse = "reverse" // Solution -> Term
+	DirectionBoth    = "both"    // Set setting only: study each card both ways
+)
+
+// Schedulers a user can choose to space their reviews
+const (
+	SchedulerSM2     = "sm2"     // Intervals grow by a per-card ease factor
+	SchedulerLeitner = "leitner" // Cards move through boxes with fixed intervals
+)
+
+// ReviewState is a user's spaced-repetition schedule for one direction of a flashcard
+type ReviewState struct {
+	gorm.Model
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_review_state"`
+	FlashcardID  uint       `gorm:"not null;uniqueIndex:idx_review_state"`
+	Direction    string     `gorm:"not null;size:10;uniqueIndex:idx_review_state"`
+	Item         string     `gorm:"not null;size:10;default:'';uniqueIndex:idx_review_state"` // Cloze number such as "c1", empty for basic cards
+	EaseFactor   float64    `gorm:"not null;default:2.5"`
+	IntervalDays int        `gorm:"not null;default:0"`
+	Repetitions  int        `gorm:"not null;default:0"` // Successful reviews in a row
+	Lapses       int        `gorm:"not null;default:0"`
+	DueAt        time.Time  `gorm:"not null;index"`
+	LastReviewed *time.Time `gorm:"default:null"`
+
+	User      User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Flashcard Flashcard `gorm:"foreignKey:FlashcardID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// Section is a named chapter within a set that flashcards can be placed in
+type Section struct {
+	gorm.Model
+	SetID    uint   `gorm:"not null;index"`
+	PublicID string `gorm:"size:100;uniqueIndex"`
+	Title    string `gorm:"not null;size:200"`
+	Position int64  `gorm:"not null;default:0"`
+
+	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
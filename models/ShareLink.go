@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ShareScopeView  = "view"  // Read the set or mind map
+	ShareScopeStudy = "study" // Read it and study it: study queues, reviews and quizzes
+)
+
+// ShareLink grants whoever holds its token access to a private set, or to
+// one mind map in it when MindMapID is set
+type ShareLink struct {
+	gorm.Model
+	PublicID   string     `gorm:"size:100;uniqueIndex"`
+	TokenHash  string     `gorm:"not null;size:64;uniqueIndex" json:"-"` // SHA-256 of the token; the token itself is only shown once
+	SetID      uint       `gorm:"not null;index"`
+	MindMapID  *uint      `gorm:"index" json:"-"`
+	Scope      string     `gorm:"not null;size:10"`
+	ExpiresAt  *time.Time `gorm:"default:null"`
+	UseCount   int        `gorm:"not null;default:0"` // Times the link has been opened; a counter only, never a limit
+	LastUsedAt *time.Time `gorm:"default:null"`
+	RevokedAt  *time.Time `gorm:"default:null"`
+
+	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	MindMap      *MindMap     `gorm:"foreignKey:MindMapID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Live reports whether the link is neither revoked nor expired at now.
+func (l *ShareLink) Live(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
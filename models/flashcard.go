@@ -6,13 +6,25 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	CardBasic = "basic" // Term and solution pair
+	CardCloze = "cloze" // Solution with {{c1::text}} deletions, one study item per cloze number
+)
+
 // Flashcard represents an individual flashcard
 type Flashcard struct {
 	gorm.Model
+	Type     string `gorm:"not null;size:10;default:basic"` // basic or cloze
 	Term     string `gorm:"not null;size:200"`
 	Solution string `gorm:"not null;size:2500"`
 	Concept  string `gorm:"size:1000"`
 	PublicID string `gorm:"size:100;uniqueIndex"`
+	Format   string `gorm:"not null;size:20;default:plain"` // plain, markdown, latex or code
+	Language string `gorm:"size:30"`                        // Language of code cards, for highlighting
+
+	ConceptID *uint `gorm:"index"`                    // Concept the card is grouped under, if any
+	SectionID *uint `gorm:"index"`                    // Section the card is placed in, if any
+	Position  int64 `gorm:"not null;default:0;index"` // Sparse rank within the set, lowest first
 
 	SetID        uint         `gorm:"not null"`
 	FlashcardSet FlashcardSet `gorm:"foreignKey:SetID" json:"-"`
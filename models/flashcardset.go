@@ -10,13 +10,16 @@ import (
 type FlashcardSet struct {
 	gorm.Model
 	Title    string    `gorm:"not null;size:100"`
-	UserID   uint      `gorm:"not null"`
+	UserID   uint      `gorm:"not null"` // Creator, and owner unless the set belongs to an organization
 	PublicID string    `gorm:"size:100;uniqueIndex"`
 	User     User      `gorm:"foreignKey:UserID" json:"-"`
 	MindMaps []MindMap `gorm:"foreignKey:SetID"` // Associated mind maps
 
 	Flashcards []Flashcard `gorm:"foreignKey:SetID"`
 
+	OrganizationID *uint `gorm:"index"` // Organization that owns the set, if any
+
 	IsPublic    bool       `gorm:"default:false"`
+	Direction   string     `gorm:"not null;size:10;default:forward"` // Which way cards are studied: forward, reverse or both
 	LastStudied *time.Time `gorm:"default:null"`
 }
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // User represents a user in the system
 type User struct {
@@ -9,4 +13,17 @@ type User struct {
 	Auth0ID       string         `gorm:"unique;not null;size:200"`
 	FlashcardSets []FlashcardSet `gorm:"foreignKey:UserID"`
 	MindMaps      []MindMap
+
+	// Profile and settings, edited through /api/me
+	DisplayName        string `gorm:"size:100"`
+	AvatarURL          string `gorm:"size:500"`
+	Bio                string `gorm:"size:1000"`
+	TimeZone           string `gorm:"not null;size:64;default:UTC"` // IANA name; study days start at midnight here
+	DailyNewCards      int    `gorm:"not null;default:10"`          // New cards introduced per day, across all sets
+	DailyReviewGoal    int    `gorm:"not null;default:100"`         // Reviews per day the user is aiming for
+	Scheduler          string `gorm:"not null;size:10;default:sm2"`
+	PublicByDefault    bool   `gorm:"not null;default:false"`          // Whether new sets are public when the request doesn't say
+	NicknameCustomized bool   `gorm:"not null;default:false" json:"-"` // Chosen through /api/me, so the identity provider's nickname no longer replaces it
+
+	DeletionScheduledAt *time.Time `gorm:"default:null;index" json:"-"` // The account and everything in it are deleted after this
 }
@@ -0,0 +1,21 @@
+// Package storage holds uploaded files behind a small blob store interface so
+// the API can keep them on local disk in development and in an S3-compatible
+// bucket in production.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get when no object exists under the key.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store saves and loads blobs by key. Keys are slash-separated paths such as
+// "media/abc123/original".
+type Store interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
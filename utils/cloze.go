@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxClozeIndex     = 50
+	clozePlaceholder  = "[...]"
+	clozeMarkerPrefix = "{{c"
+)
+
+// ClozeDeletion is one {{cN::text}} or {{cN::text::hint}} marker in a card
+type ClozeDeletion struct {
+	Index int
+	Text  string
+	Hint  string
+	start int
+	end   int
+}
+
+// ClozeItem names the study item for the deletions numbered index, e.g. "c1".
+func ClozeItem(index int) string {
+	return "c" + strconv.Itoa(index)
+}
+
+// ParseClozeItem is the inverse of ClozeItem.
+func ParseClozeItem(item string) (int, bool) {
+	if !strings.HasPrefix(item, "c") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(item[1:])
+	if err != nil || index < 1 || index > maxClozeIndex || ClozeItem(index) != item {
+		return 0, false
+	}
+	return index, true
+}
+
+// ParseCloze finds the cloze markers in text. "{{c" followed by a digit always
+// starts a marker, so anything that looks like one but doesn't parse is an error
+// rather than literal text.
+func ParseCloze(text string) ([]ClozeDeletion, error) {
+	var deletions []ClozeDeletion
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], clozeMarkerPrefix)
+		if i < 0 {
+			return deletions, nil
+		}
+		start := offset + i
+		rest := text[start+len(clozeMarkerPrefix):]
+		if rest == "" || rest[0] < '0' || rest[0] > '9' {
+			offset = start + len(clozeMarkerPrefix)
+			continue
+		}
+
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		index, _ := strconv.Atoi(rest[:digits])
+		if index < 1 || index > maxClozeIndex {
+			return nil, fmt.Errorf("cloze number must be between 1 and %d", maxClozeIndex)
+		}
+		if !strings.HasPrefix(rest[digits:], "::") {
+			return nil, fmt.Errorf("cloze marker c%d must be written {{c%d::text}}", index, index)
+		}
+		body := rest[digits+2:]
+		closing := strings.Index(body, "}}")
+		if closing < 0 {
+			return nil, fmt.Errorf("cloze marker c%d is missing its closing }}", index)
+		}
+		body = body[:closing]
+		if strings.Contains(body, "{{") {
+			return nil, errors.New("cloze markers can't be nested")
+		}
+		answer, hint, _ := strings.Cut(body, "::")
+		if strings.TrimSpace(answer) == "" {
+			return nil, fmt.Errorf("cloze marker c%d has no text", index)
+		}
+		if strings.Contains(hint, "::") {
+			return nil, fmt.Errorf("cloze marker c%d has too many :: separators", index)
+		}
+
+		end := start + len(clozeMarkerPrefix) + digits + 2 + closing + 2
+		deletions = append(deletions, ClozeDeletion{Index: index, Text: answer, Hint: hint, start: start, end: end})
+		offset = end
+	}
+}
+
+// ClozeIndexes lists the distinct cloze numbers in deletions, lowest first.
+// Each one becomes its own study item.
+func ClozeIndexes(deletions []ClozeDeletion) []int {
+	seen := make(map[int]bool, len(deletions))
+	var indexes []int
+	for _, d := range deletions {
+		if !seen[d.Index] {
+			seen[d.Index] = true
+			indexes = append(indexes, d.Index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// ValidateCloze checks text has at least one well formed cloze marker.
+func ValidateCloze(text string) error {
+	deletions, err := ParseCloze(text)
+	if err != nil {
+		return err
+	}
+	if len(deletions) == 0 {
+		return errors.New("cloze cards need at least one {{c1::text}} marker")
+	}
+	return nil
+}
+
+// replaceCloze rewrites every marker in text, passing each deletion to replace.
+func replaceCloze(text string, deletions []ClozeDeletion, replace func(ClozeDeletion) string) string {
+	var out strings.Builder
+	last := 0
+	for _, d := range deletions {
+		out.WriteString(text[last:d.start])
+		out.WriteString(replace(d))
+		last = d.end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// ClozeQuestion hides the deletions numbered index, showing their hint if
+// they have one, and reveals every other deletion.
+func ClozeQuestion(text string, index int) string {
+	deletions, err := ParseCloze(text)
+	if err != nil {
+		return text
+	}
+	return replaceCloze(text, deletions, func(d ClozeDeletion) string {
+		if d.Index != index {
+			return d.Text
+		}
+		if d.Hint != "" {
+			return "[" + d.Hint + "]"
+		}
+		return clozePlaceholder
+	})
+}
+
+// ClozeAnswer reveals every deletion.
+func ClozeAnswer(text string) string {
+	deletions, err := ParseCloze(text)
+	if err != nil {
+		return text
+	}
+	return replaceCloze(text, deletions, func(d ClozeDeletion) string { return d.Text })
+}
+
+// ClozeAnswerText joins the hidden text of the deletions numbered index.
+func ClozeAnswerText(text string, index int) string {
+	deletions, _ := ParseCloze(text)
+	var answers []string
+	for _, d := range deletions {
+		if d.Index == index {
+			answers = append(answers, d.Text)
+		}
+	}
+	return strings.Join(answers, ", ")
+}
+
+// RenderCloze renders the question (reveal false) or answer (reveal true)
+// variant of a cloze card as safe HTML. The deletions numbered index are
+// wrapped in a cloze span so clients can style them; LaTeX gets plain
+// substitutions because markup inside math would break typesetting.
+func RenderCloze(format, language, text string, index int, reveal bool) string {
+	text = strings.NewReplacer("\ue000", "", "\ue001", "").Replace(text)
+	deletions, err := ParseCloze(text)
+	if err != nil {
+		return RenderContent(format, language, text)
+	}
+	if format == FormatLatex {
+		if reveal {
+			return RenderContent(format, language, ClozeAnswer(text))
+		}
+		return RenderContent(format, language, ClozeQuestion(text, index))
+	}
+
+	// Swap the target deletions for private-use placeholders that survive
+	// rendering untouched, then put the markup in afterwards
+	var spans []string
+	withPlaceholders := replaceCloze(text, deletions, func(d ClozeDeletion) string {
+		if d.Index != index {
+			return d.Text
+		}
+		content := d.Text
+		if !reveal {
+			content = clozePlaceholder
+			if d.Hint != "" {
+				content = "[" + d.Hint + "]"
+			}
+		}
+		spans = append(spans, `<span class="cloze">`+html.EscapeString(content)+`</span>`)
+		return fmt.Sprintf("\ue000%d\ue001", len(spans)-1)
+	})
+	rendered := RenderContent(format, language, withPlaceholders)
+	for i, span := range spans {
+		rendered = strings.Replace(rendered, fmt.Sprintf("\ue000%d\ue001", i), span, 1)
+	}
+	return rendered
+}
@@ -0,0 +1,120 @@
+package utils
+
+import "testing"
+
+func TestParseCloze(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []ClozeDeletion
+		wantErr bool
+	}{
+		{"no markers", "plain text", nil, false},
+		{"one marker", "{{c1::Paris}} is a city", []ClozeDeletion{{Index: 1, Text: "Paris"}}, false},
+		{"hint", "{{c2::Paris::capital}}", []ClozeDeletion{{Index: 2, Text: "Paris", Hint: "capital"}}, false},
+		{"several", "{{c1::a}} {{c2::b}} {{c1::c}}", []ClozeDeletion{{Index: 1, Text: "a"}, {Index: 2, Text: "b"}, {Index: 1, Text: "c"}}, false},
+		{"literal braces", "{{cat}} and {{c}}", nil, false},
+		{"zero index", "{{c0::x}}", nil, true},
+		{"index too large", "{{c51::x}}", nil, true},
+		{"huge index", "{{c99999999999999999999::x}}", nil, true},
+		{"missing separator", "{{c1:x}}", nil, true},
+		{"missing text separator", "{{c1}}", nil, true},
+		{"unclosed", "{{c1::x", nil, true},
+		{"unclosed single brace", "{{c1::x}", nil, true},
+		{"empty text", "{{c1::}}", nil, true},
+		{"blank text", "{{c1::  ::hint}}", nil, true},
+		{"nested", "{{c1::a {{c2::b}} }}", nil, true},
+		{"too many separators", "{{c1::a::b::c}}", nil, true},
+		{"trailing prefix", "text {{c", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCloze(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCloze(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseCloze(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			for i := range got {
+				if got[i].Index != tt.want[i].Index || got[i].Text != tt.want[i].Text || got[i].Hint != tt.want[i].Hint {
+					t.Errorf("ParseCloze(%q)[%d] = %+v, want %+v", tt.text, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseClozeItem(t *testing.T) {
+	tests := []struct {
+		item   string
+		want   int
+		wantOK bool
+	}{
+		{"c1", 1, true},
+		{"c50", 50, true},
+		{"c0", 0, false},
+		{"c51", 0, false},
+		{"c01", 0, false},
+		{"c+1", 0, false},
+		{"1", 0, false},
+		{"c", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseClozeItem(tt.item)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseClozeItem(%q) = %d, %v, want %d, %v", tt.item, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestClozeQuestionAndAnswer(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		index        int
+		wantQuestion string
+		wantAnswer   string
+	}{
+		{"hides target", "{{c1::a}} {{c2::b}}", 1, "[...] b", "a b"},
+		{"shows hint", "{{c1::a::letter}} {{c2::b}}", 1, "[letter] b", "a b"},
+		{"hides every matching marker", "{{c1::a}} {{c1::b}}", 1, "[...] [...]", "a b"},
+		{"malformed left alone", "{{c1::a", 1, "{{c1::a", "{{c1::a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClozeQuestion(tt.text, tt.index); got != tt.wantQuestion {
+				t.Errorf("ClozeQuestion(%q, %d) = %q, want %q", tt.text, tt.index, got, tt.wantQuestion)
+			}
+			if got := ClozeAnswer(tt.text); got != tt.wantAnswer {
+				t.Errorf("ClozeAnswer(%q) = %q, want %q", tt.text, got, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestRenderCloze(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		text   string
+		reveal bool
+		want   string
+	}{
+		{"question", FormatPlain, "{{c1::a}} b", false, `<p><span class="cloze">[...]</span> b</p>`},
+		{"answer", FormatPlain, "{{c1::a}} b", true, `<p><span class="cloze">a</span> b</p>`},
+		{"escapes answer", FormatPlain, "{{c1::<script>}}", true, `<p><span class="cloze">&lt;script&gt;</span></p>`},
+		{"escapes hint", FormatPlain, "{{c1::a::<b>}}", false, `<p><span class="cloze">[&lt;b&gt;]</span></p>`},
+		{"strips forged placeholders", FormatPlain, "0{{c1::a}}", true, `<p>0<span class="cloze">a</span></p>`},
+		{"latex", FormatLatex, `\frac{{{c1::1}}}{2}`, false, `<div class="math-display">\frac{[...]}{2}</div>`},
+		{"malformed", FormatPlain, "{{c1::<i>", false, "<p>{{c1::&lt;i&gt;</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderCloze(tt.format, "", tt.text, 1, tt.reveal); got != tt.want {
+				t.Errorf("RenderCloze(%q, %q, %v) = %q, want %q", tt.format, tt.text, tt.reveal, got, tt.want)
+			}
+		})
+	}
+}
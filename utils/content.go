@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content formats a flashcard's term and solution can be written in
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatLatex    = "latex"
+	FormatCode     = "code"
+)
+
+var codeLanguagePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]{0,29}$`)
+
+// unsafeLatexCommands can load files, run shell escapes or emit links and raw
+// HTML in common client-side renderers, so they're never accepted.
+var unsafeLatexCommands = []string{
+	`\href`, `\url`, `\includegraphics`, `\input`, `\include`, `\write`, `\immediate`,
+	`\openout`, `\read`, `\catcode`, `\def`, `\let`, `\csname`, `\htmlClass`, `\htmlId`,
+	`\htmlStyle`, `\htmlData`, `\html`,
+}
+
+// ValidateContent checks that text is well formed for format. Language only
+// applies to code and must be a short lowercase identifier such as "go" or "c++".
+func ValidateContent(format, language, text string) error {
+	if !utf8.ValidString(text) {
+		return errors.New("content must be valid UTF-8")
+	}
+	if strings.ContainsRune(text, 0) {
+		return errors.New("content must not contain NUL characters")
+	}
+	switch format {
+	case FormatPlain, FormatMarkdown:
+	case FormatLatex:
+		return validateLatex(text)
+	case FormatCode:
+		if language != "" && !codeLanguagePattern.MatchString(language) {
+			return fmt.Errorf("invalid code language %q", language)
+		}
+	default:
+		return fmt.Errorf("unknown content format %q", format)
+	}
+	if language != "" && format != FormatCode {
+		return errors.New("language only applies to code")
+	}
+	return nil
+}
+
+func validateLatex(text string) error {
+	depth := 0
+	for i, r := range text {
+		switch r {
+		case '{':
+			if i == 0 || text[i-1] != '\\' {
+				depth++
+			}
+		case '}':
+			if i == 0 || text[i-1] != '\\' {
+				depth--
+			}
+		}
+		if depth < 0 {
+			return errors.New("unbalanced braces in LaTeX")
+		}
+	}
+	if depth != 0 {
+		return errors.New("unbalanced braces in LaTeX")
+	}
+	for _, command := range unsafeLatexCommands {
+		for start := 0; ; {
+			i := strings.Index(text[start:], command)
+			if i < 0 {
+				break
+			}
+			end := start + i + len(command)
+			// A longer command name that merely starts with this one is a different command
+			if end == len(text) || !isLatexLetter(text[end]) {
+				return fmt.Errorf("LaTeX command %s is not allowed", command)
+			}
+			start = end
+		}
+	}
+	return nil
+}
+
+func isLatexLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// RenderContent turns text in the given format into HTML that is safe to
+// insert into a page. Everything the author wrote is escaped; the only markup
+// in the output is generated here. LaTeX is left as escaped source inside
+// math elements for the client to typeset.
+func RenderContent(format, language, text string) string {
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(text)
+	case FormatLatex:
+		return `<div class="math-display">` + html.EscapeString(text) + `</div>`
+	case FormatCode:
+		class := ""
+		if codeLanguagePattern.MatchString(language) {
+			class = ` class="language-` + language + `"`
+		}
+		return `<pre><code` + class + `>` + html.EscapeString(text) + `</code></pre>`
+	default:
+		return `<p>` + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + `</p>`
+	}
+}
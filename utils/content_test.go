@@ -0,0 +1,61 @@
+package utils
+
+import "testing"
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		language string
+		text     string
+		wantErr  bool
+	}{
+		{"plain", FormatPlain, "", "hello", false},
+		{"markdown", FormatMarkdown, "", "**hi**", false},
+		{"invalid utf8", FormatPlain, "", "\xff", true},
+		{"nul", FormatPlain, "", "a\x00b", true},
+		{"unknown format", "html", "", "x", true},
+		{"code language", FormatCode, "c++", "int x;", false},
+		{"code bad language", FormatCode, `go" onclick="x`, "x", true},
+		{"language outside code", FormatMarkdown, "go", "x", true},
+		{"latex", FormatLatex, "", `\frac{1}{2}`, false},
+		{"latex escaped brace", FormatLatex, "", `\{x\}`, false},
+		{"latex unbalanced", FormatLatex, "", `\frac{1}{2`, true},
+		{"latex close first", FormatLatex, "", `}{`, true},
+		{"latex href", FormatLatex, "", `\href{javascript:alert(1)}{x}`, true},
+		{"latex html", FormatLatex, "", `\htmlData{x=y}{z}`, true},
+		{"latex input", FormatLatex, "", `\input{/etc/passwd}`, true},
+		{"latex longer command", FormatLatex, "", `\inputs`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateContent(tt.format, tt.language, tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateContent(%q, %q, %q) error = %v, wantErr %v", tt.format, tt.language, tt.text, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		language string
+		text     string
+		want     string
+	}{
+		{"plain", FormatPlain, "", "<script>\nx", "<p>&lt;script&gt;<br>x</p>"},
+		{"latex", FormatLatex, "", `</div><script>`, `<div class="math-display">&lt;/div&gt;&lt;script&gt;</div>`},
+		{"code", FormatCode, "go", "</code>", `<pre><code class="language-go">&lt;/code&gt;</code></pre>`},
+		{"code bad language", FormatCode, `x"><script>`, "y", `<pre><code>y</code></pre>`},
+		{"markdown", FormatMarkdown, "", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderContent(tt.format, tt.language, tt.text); got != tt.want {
+				t.Errorf("RenderContent(%q, %q, %q) = %q, want %q", tt.format, tt.language, tt.text, got, tt.want)
+			}
+		})
+	}
+}
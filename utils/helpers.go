@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 
 	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
@@ -14,3 +16,22 @@ func GetAuth0ID(r *http.Request) (string, bool) {
 	}
 	return claims.RegisteredClaims.Subject, true
 }
+
+// AccessTokenPrefix starts every personal access token, which is how a bearer
+// token is told apart from a JWT
+const AccessTokenPrefix = "nbp_"
+
+// AccessTokenIssuer is the issuer on claims built from a personal access token
+const AccessTokenIssuer = "nodebook-api/personal-access-token"
+
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ViaAccessToken reports whether the request was authenticated with a
+// personal access token rather than a JWT.
+func ViaAccessToken(r *http.Request) bool {
+	claims, ok := r.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
+	return ok && claims.RegisteredClaims.Issuer == AccessTokenIssuer
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	unorderedPattern   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern     = regexp.MustCompile(`^\s*\d{1,9}[.)]\s+(.*)$`)
+	rulePattern        = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	fencePattern       = regexp.MustCompile("^\\s*```\\s*([A-Za-z0-9+#._-]*)\\s*$")
+	codeSpanPattern    = regexp.MustCompile("`([^`]+)`")
+	inlineMathPattern  = regexp.MustCompile(`\$([^$\n]+)\$`)
+	linkPattern        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldPattern        = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicPattern      = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	strikePattern      = regexp.MustCompile(`~~([^~]+)~~`)
+	placeholderPattern = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// renderMarkdown supports the subset of Markdown flashcards need: headings,
+// paragraphs, lists, block quotes, rules, fenced code, $$ display math and
+// the usual inline marks. Raw HTML is never passed through.
+func renderMarkdown(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out strings.Builder
+	var paragraph []string
+	listTag := ""
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			out.WriteString("</" + listTag + ">")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			out.WriteString("<" + tag + ">")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !fencePattern.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString(RenderContent(FormatCode, strings.ToLower(m[1]), strings.Join(code, "\n")))
+			continue
+		}
+		if strings.TrimSpace(line) == "$$" {
+			flushParagraph()
+			closeList()
+			var math []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "$$"; i++ {
+				math = append(math, lines[i])
+			}
+			out.WriteString(RenderContent(FormatLatex, "", strings.Join(math, "\n")))
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flushParagraph()
+			closeList()
+		case rulePattern.MatchString(line):
+			flushParagraph()
+			closeList()
+			out.WriteString("<hr>")
+		case headingPattern.MatchString(line):
+			flushParagraph()
+			closeList()
+			m := headingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			out.WriteString("<h" + level + ">" + renderInline(strings.TrimRight(m[2], " #")) + "</h" + level + ">")
+		case unorderedPattern.MatchString(line):
+			flushParagraph()
+			openList("ul")
+			out.WriteString("<li>" + renderInline(unorderedPattern.FindStringSubmatch(line)[1]) + "</li>")
+		case orderedPattern.MatchString(line):
+			flushParagraph()
+			openList("ol")
+			out.WriteString("<li>" + renderInline(orderedPattern.FindStringSubmatch(line)[1]) + "</li>")
+		case strings.HasPrefix(strings.TrimSpace(line), ">"):
+			flushParagraph()
+			closeList()
+			quote := strings.TrimPrefix(strings.TrimSpace(line), ">")
+			out.WriteString("<blockquote>" + renderInline(strings.TrimSpace(quote)) + "</blockquote>")
+		default:
+			closeList()
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushParagraph()
+	closeList()
+	return out.String()
+}
+
+// renderInline escapes text and then adds inline markup. Code spans and math
+// are swapped out for placeholders first so their contents aren't formatted.
+func renderInline(text string) string {
+	var protected []string
+	protect := func(fragment string) string {
+		protected = append(protected, fragment)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+
+	text = strings.ReplaceAll(text, "\x00", "")
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return protect("<code>" + html.EscapeString(m[1:len(m)-1]) + "</code>")
+	})
+	text = inlineMathPattern.ReplaceAllStringFunc(text, func(m string) string {
+		return protect(`<span class="math-inline">` + html.EscapeString(m[1:len(m)-1]) + `</span>`)
+	})
+	text = linkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := linkPattern.FindStringSubmatch(m)
+		label := renderMarks(html.EscapeString(parts[1]))
+		if !safeLinkTarget(parts[2]) {
+			return protect(label)
+		}
+		return protect(`<a href="` + html.EscapeString(parts[2]) + `" rel="nofollow noopener noreferrer">` + label + `</a>`)
+	})
+
+	text = renderMarks(html.EscapeString(text))
+	text = strings.ReplaceAll(text, "\n", "<br>")
+	// A link's label can hold code or math placeholders of its own. They always
+	// point at earlier fragments, so the recursion ends.
+	var restore func(string) string
+	restore = func(fragment string) string {
+		return placeholderPattern.ReplaceAllStringFunc(fragment, func(m string) string {
+			i, _ := strconv.Atoi(m[1 : len(m)-1])
+			return restore(protected[i])
+		})
+	}
+	return restore(text)
+}
+
+// renderMarks applies bold, italic and strikethrough to already escaped text.
+func renderMarks(text string) string {
+	text = boldPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = italicPattern.ReplaceAllString(text, "<em>$1$2</em>")
+	return strikePattern.ReplaceAllString(text, "<del>$1</del>")
+}
+
+// safeLinkTarget allows web and mail links plus relative paths and fragments,
+// which rules out javascript:, data: and other script-capable schemes.
+// Browsers read "/\host" like "//host", so a relative path can't start that
+// way either. Targets holding a code or math placeholder are refused.
+func safeLinkTarget(target string) bool {
+	if strings.Contains(target, "\x00") {
+		return false
+	}
+	lower := strings.ToLower(target)
+	for _, prefix := range []string{"https://", "http://", "mailto:"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return (strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\")) ||
+		strings.HasPrefix(target, "#")
+}
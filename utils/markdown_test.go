@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraph", "hello", "<p>hello</p>"},
+		{"escapes html", "<b>x</b>", "<p>&lt;b&gt;x&lt;/b&gt;</p>"},
+		{"code span", "a `<i>` b", "<p>a <code>&lt;i&gt;</code> b</p>"},
+		{"link", "[a](https://a.com)", `<p><a href="https://a.com" rel="nofollow noopener noreferrer">a</a></p>`},
+		{"code in link label", "[`x`](https://a.com)", `<p><a href="https://a.com" rel="nofollow noopener noreferrer"><code>x</code></a></p>`},
+		{"math in link label", "[$x$](#a)", `<p><a href="#a" rel="nofollow noopener noreferrer"><span class="math-inline">x</span></a></p>`},
+		{"relative link", "[a](/ok)", `<p><a href="/ok" rel="nofollow noopener noreferrer">a</a></p>`},
+		{"javascript link", "[a](javascript:alert%281%29)", "<p>a</p>"},
+		{"javascript link mixed case", "[a](JaVaScRiPt:alert%281%29)", "<p>a</p>"},
+		{"data link", "[a](data:text/html,x)", "<p>a</p>"},
+		{"scheme relative link", "[a](//evil.com)", "<p>a</p>"},
+		{"backslash relative link", `[a](/\evil.com)`, "<p>a</p>"},
+		{"code in link target", "[a](`x`)", "<p>a</p>"},
+		{"raw nul", "a\x000\x00b", "<p>a0b</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.in); got != tt.want {
+				t.Errorf("renderMarkdown(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownNeverEmitsAuthorMarkup(t *testing.T) {
+	inputs := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[x](https://a.com\"onmouseover=\"alert(1))",
+		"**<svg onload=alert(1)>**",
+		"```\n</code><script>alert(1)</script>\n```",
+		"```\"><script>\nx\n```",
+		"$$\n</div><script>alert(1)</script>\n$$",
+		"# <iframe>",
+		"- <a href=javascript:x>",
+		"> <style>",
+	}
+	for _, in := range inputs {
+		got := renderMarkdown(in)
+		for _, bad := range []string{"<script", "<img", "<svg", "<iframe", "<style", "<a href=javascript", `"onmouseover`} {
+			if strings.Contains(strings.ToLower(got), bad) {
+				t.Errorf("renderMarkdown(%q) = %q, contains %s", in, got, bad)
+			}
+		}
+	}
+}